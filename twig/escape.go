@@ -56,6 +56,8 @@ func NewAutoEscapeExtension() *AutoEscapeExtension {
 			"js":        escape.JS,
 			"css":       escape.CSS,
 			"url":       escape.URLQueryParam,
+			"icu":       escape.ICU,
+			"json":      escape.JSON,
 		},
 	}
 }
@@ -91,6 +93,11 @@ func (v *autoEscapeVisitor) Enter(n parse.Node) {
 	case *parse.BlockNode:
 		v.push(v.guessTypeFromName(node.Origin))
 	case *parse.PrintNode:
+		if f, ok := node.X.(*parse.FilterExpr); ok && f.Name == "raw" {
+			// Like Twig, printing the result of raw disables escaping for
+			// every strategy, not only for html.
+			return
+		}
 		ct := v.current()
 		v := node.X
 		r := parse.NewFilterExpr(
@@ -116,5 +123,5 @@ func (v *autoEscapeVisitor) guessTypeFromName(name string) string {
 		// Default to html
 		return "html"
 	}
-	return name[p:]
+	return name[p+1:]
 }
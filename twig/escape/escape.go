@@ -4,6 +4,7 @@ package escape
 import (
 	"bytes"
 	"fmt"
+	"strings"
 )
 
 // HTML provides a Twig-compatible HTML escape function.
@@ -109,3 +110,49 @@ func URLQueryParam(in string) string {
 	}
 	return out.String()
 }
+
+// ICU provides an escaper for literal text embedded in ICU MessageFormat
+// patterns. Apostrophes are doubled and braces are quoted, so they are
+// rendered literally rather than parsed as message syntax.
+func ICU(in string) string {
+	var out = &bytes.Buffer{}
+	for _, c := range strings.Replace(in, "'", "''", -1) {
+		if c == 123 || c == 125 {
+			// { }
+			out.WriteRune(39)
+			out.WriteRune(c)
+			out.WriteRune(39)
+		} else {
+			// UTF-8
+			out.WriteRune(c)
+		}
+	}
+	return out.String()
+}
+
+// JSON provides an escaper for content embedded in a JSON string literal.
+// Quotes, backslashes and control characters are escaped, as are "<", ">"
+// and "&" so the output cannot close a surrounding script element.
+func JSON(in string) string {
+	var out = &bytes.Buffer{}
+	for _, c := range in {
+		if c == 34 || c == 92 {
+			// " \
+			out.WriteRune(92)
+			out.WriteRune(c)
+		} else if c == 10 {
+			out.WriteString("\\n")
+		} else if c == 13 {
+			out.WriteString("\\r")
+		} else if c == 9 {
+			out.WriteString("\\t")
+		} else if c < 32 || c == 38 || c == 60 || c == 62 || c == 0x2028 || c == 0x2029 {
+			// Control characters, & < > and line/paragraph separators
+			fmt.Fprintf(out, "\\u%04X", c)
+		} else {
+			// UTF-8
+			out.WriteRune(c)
+		}
+	}
+	return out.String()
+}
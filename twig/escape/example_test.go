@@ -40,3 +40,17 @@ func ExampleURLQueryParam() {
 	// Output:
 	// ?who=%D7%9E%D7%99%D7%99%D7%9F%20%D7%9E%D7%90%D7%9E%D7%A2%D7%9D
 }
+
+func ExampleICU() {
+	input := "Don't {name} me"
+	fmt.Printf("{count, plural, other {%s}}", escape.ICU(input))
+	// Output:
+	// {count, plural, other {Don''t '{'name'}' me}}
+}
+
+func ExampleJSON() {
+	input := "say \"hi\"\n</script>"
+	fmt.Printf("{\"text\": \"%s\"}", escape.JSON(input))
+	// Output:
+	// {"text": "say \"hi\"\n\u003C/script\u003E"}
+}
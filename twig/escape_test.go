@@ -1,6 +1,7 @@
 package twig_test
 
 import (
+	"bytes"
 	"testing"
 
 	"os"
@@ -78,3 +79,46 @@ func TestAutoEscapeVisitor(t *testing.T) {
 		t.Errorf("expected 'text', got %s", fv)
 	}
 }
+
+func TestRawFilter(t *testing.T) {
+	env := twig.New(nil)
+	tests := map[string]string{
+		`{{ "<b>"|raw }}`:                "<b>",
+		`{{ ('<b>'|raw)|escape('js') }}`: `\u003Cb\u003E`,
+		`{{ "<b>" }} {{ markup|raw }}`:   "&lt;b&gt; <i>",
+	}
+	for tpl, expected := range tests {
+		buf := &bytes.Buffer{}
+		err := env.Execute(tpl, buf, map[string]stick.Value{"markup": "<i>"})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tpl, err)
+			continue
+		}
+		if actual := buf.String(); actual != expected {
+			t.Errorf("%s: expected %q, got %q", tpl, expected, actual)
+		}
+	}
+}
+
+func TestRawFilterStrategies(t *testing.T) {
+	tests := map[string]string{
+		"a.html.twig": "<b> &lt;b&gt;",
+		"a.js.twig":   `<b> \u003Cb\u003E`,
+		"a.css.twig":  `<b> \003Cb\003E`,
+		"a.url.twig":  "<b> %3Cb%3E",
+		"a.json.twig": `<b> \u003Cb\u003E`,
+	}
+	for name, expected := range tests {
+		tpl := `{{ "<b>"|raw }} {{ "<b>" }}`
+		env := twig.New(&stick.MemoryLoader{Templates: map[string]string{name: tpl}})
+		buf := &bytes.Buffer{}
+		err := env.Execute(name, buf, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+			continue
+		}
+		if actual := buf.String(); actual != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, actual)
+		}
+	}
+}
@@ -2,16 +2,33 @@
 package filter
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"math"
+	"math/rand"
+	"net"
+	"net/mail"
 	"net/url"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf16"
 	"unicode/utf8"
 
 	"reflect"
+	"sort"
 	"time"
 
 	"github.com/polakto/stick"
+	"github.com/polakto/stick/twig/escape"
+	"golang.org/x/net/html"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/bidi"
+	"golang.org/x/text/unicode/norm"
 )
 
 const (
@@ -28,19 +45,29 @@ var DatePatternTokensMap = map[string]string{
 	"yy":   "06",
 
 	// month
-	"MM": "01", // 01-12
-	"M":  "1",  // 1-12
+	"MMMM": "January", // January-December
+	"MMM":  "Jan",     // Jan-Dec
+	"MM":   "01",      // 01-12
+	"M":    "1",       // 1-12
 
 	// day
 	"dd": "02", // 01-07
 	"d":  "2",  // 1-7
 
+	// weekday
+	"EEEE": "Monday", // Monday-Sunday
+	"EEE":  "Mon",    // Mon-Sun
+
 	// hours
 	"hh": "03", // 01-12
 	"h":  "3",  // 1-12
 	"HH": "15", // 0 - 24
 	"H":  "15", // 0 - 24 - not possible, will be 00 - 24
 
+	// meridiem
+	"a": "pm", // am, pm
+	"A": "PM", // AM, PM
+
 	// minutes
 	"mm": "04", // 00 - 59
 	"m":  "4",  // 0 - 59
@@ -48,34 +75,107 @@ var DatePatternTokensMap = map[string]string{
 	// seconds
 	"ss": "05", // 00-59
 	"s":  "5",  // 0-59
-}
 
-var DatePatternTokensSlice = []string{
-	"yyyy",
-	"yyy",
-	"yy",
-	"MM",
-	"M",
-	"dd",
-	"d",
-	"hh",
-	"h",
-	"HH",
-	"H",
-	"mm",
-	"m",
-	"ss",
-	"s",
+	// fractional seconds, only recognized by Go when following a "."
+	"SSS": "000", // 000-999
+
+	// time zone
+	"ZZ": "Z07:00", // Z, +08:00
+	"Z":  "-0700",  // +0000, +0800
 }
 
+// StandardDatePatternToGoDatePattern converts a standard date pattern such
+// as "yyyy-MM-dd hh:mm" into a Go time layout. The pattern is scanned from
+// left to right, replacing the longest token in DatePatternTokensMap found at
+// each position, so the Go layout produced for one token is never mistaken
+// for another token. Characters that are not part of a token are kept as-is.
+// Text enclosed in single quotes is not matched against tokens, and two
+// consecutive single quotes produce a literal quote, so "HH'h'mm" converts
+// to "15h04".
+//
+// Go layouts cannot escape literal text, so literals that resemble a Go
+// layout element, such as "Mon" or "2", are still interpreted by
+// time.Time.Format. Use FormatStandardDate to format a date with literals
+// kept verbatim.
+//
 // by polakto
 func StandardDatePatternToGoDatePattern(stdPattern string) string {
-	goPattern := stdPattern
-	for key := range DatePatternTokensSlice {
-		goPattern = strings.Replace(goPattern, DatePatternTokensSlice[key], DatePatternTokensMap[DatePatternTokensSlice[key]], -1)
+	goPattern := &bytes.Buffer{}
+	scanDatePattern(stdPattern, func(s string, literal bool) {
+		goPattern.WriteString(s)
+	})
+	return goPattern.String()
+}
+
+// FormatStandardDate returns t formatted according to the standard date
+// pattern stdPattern. Unlike formatting with the layout returned by
+// StandardDatePatternToGoDatePattern, literal text is always output verbatim.
+func FormatStandardDate(t time.Time, stdPattern string) string {
+	out := &bytes.Buffer{}
+	scanDatePattern(stdPattern, func(s string, literal bool) {
+		if literal {
+			out.WriteString(s)
+		} else if strings.Trim(s, "0") == "" {
+			// Fractional seconds are formatted on their own.
+			out.WriteString(t.Format("." + s)[1:])
+		} else {
+			out.WriteString(t.Format(s))
+		}
+	})
+	return out.String()
+}
 
+// scanDatePattern scans the standard date pattern stdPattern from left to
+// right, calling emit with the Go layout of each token, or with literal text
+// and literal set to true.
+func scanDatePattern(stdPattern string, emit func(s string, literal bool)) {
+	maxLen := 0
+	for token := range DatePatternTokensMap {
+		if len(token) > maxLen {
+			maxLen = len(token)
+		}
+	}
+	for i := 0; i < len(stdPattern); {
+		if stdPattern[i] == '\'' {
+			if strings.HasPrefix(stdPattern[i+1:], "'") {
+				emit("'", true)
+				i += 2
+				continue
+			}
+			// Quoted literal, where '' is an escaped quote. An unterminated
+			// literal runs to the end of the pattern.
+			lit := &bytes.Buffer{}
+			i++
+			for i < len(stdPattern) {
+				if stdPattern[i] == '\'' {
+					if !strings.HasPrefix(stdPattern[i+1:], "'") {
+						i++
+						break
+					}
+					i++
+				}
+				lit.WriteByte(stdPattern[i])
+				i++
+			}
+			emit(lit.String(), true)
+			continue
+		}
+		n := maxLen
+		if rest := len(stdPattern) - i; n > rest {
+			n = rest
+		}
+		for ; n > 0; n-- {
+			if layout, ok := DatePatternTokensMap[stdPattern[i:i+n]]; ok {
+				emit(layout, false)
+				break
+			}
+		}
+		if n == 0 {
+			emit(stdPattern[i:i+1], true)
+			n = 1
+		}
+		i += n
 	}
-	return goPattern
 }
 
 // builtInFilters returns a map containing all built-in Twig filters,
@@ -114,9 +214,83 @@ func TwigFilters() map[string]stick.Filter {
 		"url_encode":       filterURLEncode,
 
 		// custom
-		"get":      filterGet,
-		"dateTime": filterDateTime,
-		"time":     filterTime,
+		"get":                 filterGet,
+		"dateTime":            filterDateTime,
+		"time":                filterTime,
+		"take":                filterTake,
+		"drop":                filterDrop,
+		"partition":           filterPartition,
+		"transpose":           filterTranspose,
+		"to_tree":             filterToTree,
+		"flatten_tree":        filterFlattenTree,
+		"wrap":                filterWrap,
+		"unwrap":              filterUnwrap,
+		"valid":               filterValid,
+		"fraction":            filterFraction,
+		"version_compare":     filterVersionCompare,
+		"summarize":           filterSummarize,
+		"reorder":             filterReorder,
+		"fragment_encode":     filterFragmentEncode,
+		"mailto":              filterMailto,
+		"css_style":           filterCSSStyle,
+		"parse_style":         filterParseStyle,
+		"nullsafe":            filterNullsafe,
+		"count_values":        filterCountValues,
+		"duplicates":          filterDuplicates,
+		"sample":              filterSample,
+		"rotate":              filterRotate,
+		"key_by":              filterKeyBy,
+		"canonical_json":      filterCanonicalJSON,
+		"signed":              filterSigned,
+		"clamp":               filterClamp,
+		"rescale":             filterRescale,
+		"stars":               filterStars,
+		"strip_bom":           filterStripBOM,
+		"squeeze":             filterSqueeze,
+		"reverse_words":       filterReverseWords,
+		"swapcase":            filterSwapCase,
+		"center":              filterCenter,
+		"enclose":             filterEnclose,
+		"html_list":           filterHTMLList,
+		"html_table":          filterHTMLTable,
+		"dl":                  filterDL,
+		"to_json_array":       filterToJSONArray,
+		"scientific":          filterScientific,
+		"mod":                 filterMod,
+		"power":               filterPower,
+		"gcd":                 filterGCD,
+		"lcm":                 filterLCM,
+		"smart_truncate_html": filterSmartTruncateHTML,
+		"text_direction":      filterTextDirection,
+		"bidi_isolate":        filterBidiIsolate,
+		"normalize":           filterNormalize,
+		"strip_control":       filterStripControl,
+		"to_duration":         filterToDuration,
+		"business_days":       filterBusinessDays,
+		"iso8601":             filterISO8601,
+		"timestamp":           filterTimestamp,
+		"date_bucket":         filterDateBucket,
+		"list_and":            filterListAnd,
+		"json_escape":         filterJSONEscape,
+		"class_list":          filterClassList,
+		"checkmark":           filterCheckmark,
+		"reading_time":        filterReadingTime,
+		"hashtags":            filterHashtags,
+		"mentions":            filterMentions,
+		"anonymize_ip":        filterAnonymizeIP,
+		"coordinate":          filterCoordinate,
+		"pct_change":          filterPctChange,
+		"rank":                filterRank,
+		"break_long_words":    filterBreakLongWords,
+		"natsort":             filterNatSort,
+		"string_to_color":     filterStringToColor,
+		"morse":               filterMorse,
+		"radix":               filterRadix,
+		"sentence_case":       filterSentenceCase,
+		"initials":            filterInitials,
+		"rot13":               filterRot13,
+		"caesar":              filterRot13,
+		"bool_string":         filterBoolString,
 	}
 }
 
@@ -184,55 +358,117 @@ func filterBatch(ctx stick.Context, val stick.Value, args ...stick.Value) stick.
 	return out
 }
 
-// filterCapitalize takes no arguments and returns val with the first
-// character capitalized.
+// filterCapitalize takes one optional argument, the locale, and returns val
+// with the first character in title case, so "ßa" becomes "Ssa". If no
+// locale is given, the "locale" metadata attribute of the context is used.
 func filterCapitalize(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
 	s := stick.CoerceString(val)
-	return strings.ToUpper(s[:1]) + s[1:]
+	_, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return cases.Title(caseLanguage(ctx, args...)).String(s[:size]) + s[size:]
 }
 
+// filterConvertEncoding takes two arguments, the target and source charset
+// names, and returns val converted from the source to the target charset.
+// Charsets are looked up by their IANA names, such as "UTF-8", "ISO-8859-1"
+// and "Windows-1252". Value nil is returned if either charset is unknown or
+// val cannot be converted.
 func filterConvertEncoding(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	if len(args) < 2 {
+		// TODO: Report error
+		return nil
+	}
+	to, err := ianaindex.IANA.Encoding(stick.CoerceString(args[0]))
+	if err != nil || to == nil {
+		// TODO: Report error
+		return nil
+	}
+	from, err := ianaindex.IANA.Encoding(stick.CoerceString(args[1]))
+	if err != nil || from == nil {
+		// TODO: Report error
+		return nil
+	}
+	s := stick.CoerceString(val)
+	if to == from {
+		return s
+	}
+	decoded, err := from.NewDecoder().String(s)
+	if err != nil {
+		// TODO: Report error
+		return nil
+	}
+	res, err := to.NewEncoder().String(decoded)
+	if err != nil {
+		// TODO: Report error
+		return nil
+	}
+	return res
 }
 
+// filterDate takes up to two optional arguments, a standard date pattern
+// (defaults to FilterDateDefaultLayout) and an IANA timezone name, and
+// returns the date val formatted according to the pattern. Value val may be a
+// time.Time, a Unix timestamp or a MariaDB date string. If a timezone is
+// given, strings without an offset are parsed in it and other dates are
+// converted into it; unknown timezones fall back to UTC.
 func filterDate(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
 	requestedLayout := FilterDateDefaultLayout
+	loc := time.UTC
+
+	l := len(args)
+	if l >= 1 {
+		requestedLayout = stick.CoerceString(args[0])
+		if l >= 2 {
+			loc = coerceLocation(args[1])
+		}
+	}
 
-	strD := stick.CoerceString(val)
-	d, conversionErr := convertMariaDBDate(strD)
+	d, conversionErr := parseDateValue(val, func(in string) (time.Time, error) {
+		return convertMariaDBDate(in, loc)
+	})
 	if conversionErr != nil {
 		return nil
 	}
-
-	if l := len(args); l >= 1 {
-		requestedLayout = stick.CoerceString(args[0])
+	if l >= 2 {
+		d = d.In(loc)
 	}
 
-	return fmt.Sprintf("\n %s", d.Format(StandardDatePatternToGoDatePattern(requestedLayout)))
+	return FormatStandardDate(d, requestedLayout)
 }
 
+// filterDateTime is like filterDate, but strings are parsed as a MariaDB
+// datetime in UTC and converted into the timezone, and the pattern defaults
+// to FilterDateTimeDefaultLayout.
 func filterDateTime(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
 	requestedLayout := FilterDateTimeDefaultLayout
 
-	strD := stick.CoerceString(val)
-	d, conversionErr := convertMariaDBDateTime(strD)
+	d, conversionErr := coerceTime(val)
 	if conversionErr != nil {
 		return nil
 	}
 
 	if l := len(args); l >= 1 {
 		requestedLayout = stick.CoerceString(args[0])
+		if l >= 2 {
+			d = d.In(coerceLocation(args[1]))
+		}
 	}
 
-	return fmt.Sprintf("\n %s", d.Format(StandardDatePatternToGoDatePattern(requestedLayout)))
+	return FormatStandardDate(d, requestedLayout)
 }
 
+// filterTime takes one optional argument, a standard date pattern (defaults
+// to FilterTimeDefaultLayout), and returns the time val formatted according
+// to the pattern. Value val may be a time.Time, a Unix timestamp or a MariaDB
+// time string.
 func filterTime(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
 	requestedLayout := FilterTimeDefaultLayout
 
-	strD := stick.CoerceString(val)
-	d, conversionErr := convertMariaDBTime(strD)
+	d, conversionErr := parseDateValue(val, func(in string) (time.Time, error) {
+		return convertMariaDBTime(in, time.UTC)
+	})
 	if conversionErr != nil {
 		return nil
 	}
@@ -241,47 +477,149 @@ func filterTime(ctx stick.Context, val stick.Value, args ...stick.Value) stick.V
 		requestedLayout = stick.CoerceString(args[0])
 	}
 
-	return fmt.Sprintf("\n %s", d.Format(StandardDatePatternToGoDatePattern(requestedLayout)))
+	return FormatStandardDate(d, requestedLayout)
 }
 
 // filter date, time, datetime helpers
-func convertMariaDBDate(in string) (time.Time, error) {
-	t, err := time.Parse("2006-01-02", in)
-	if err != nil {
-		return time.Time{}, err
+
+// MariaDBLayouts lists the layouts accepted when parsing date, datetime and
+// time strings, in order of priority. Fractional seconds are accepted after
+// the seconds in every layout.
+var MariaDBLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"15:04:05",
+}
+
+func convertMariaDBDate(in string, loc *time.Location) (time.Time, error) {
+	return parseMariaDBLayouts(in, "2006-01-02", loc)
+}
+
+func convertMariaDBTime(in string, loc *time.Location) (time.Time, error) {
+	return parseMariaDBLayouts(in, "15:04:05", loc)
+}
+
+func convertMariaDBDateTime(in string, loc *time.Location) (time.Time, error) {
+	return parseMariaDBLayouts(in, "2006-01-02 15:04:05", loc)
+}
+
+// parseMariaDBLayouts parses in with the preferred layout, falling back to
+// the layouts in MariaDBLayouts, and returns the first successful result.
+// Strings without an offset are parsed in loc.
+func parseMariaDBLayouts(in string, preferred string, loc *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation(preferred, in, loc)
+	if err == nil {
+		return t, nil
 	}
-	return t, nil
+	for _, layout := range MariaDBLayouts {
+		if t, lerr := time.ParseInLocation(layout, in, loc); lerr == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
 }
 
-func convertMariaDBTime(in string) (time.Time, error) {
-	t, err := time.Parse("15:04:05", in)
+// coerceLocation returns the location named by the IANA timezone name val.
+// Unknown timezones fall back to UTC.
+func coerceLocation(val stick.Value) *time.Location {
+	loc, err := time.LoadLocation(stick.CoerceString(val))
 	if err != nil {
-		return time.Time{}, err
+		return time.UTC
 	}
-	return t, nil
+	return loc
 }
 
-func convertMariaDBDateTime(in string) (time.Time, error) {
-	t, err := time.Parse("2006-01-02 15:04:05", in)
-	if err != nil {
-		return time.Time{}, err
+// parseDateValue converts val into a time.Time. Values of type time.Time
+// are returned as-is, and numbers are treated as seconds since the Unix
+// epoch, in UTC. Anything else is coerced into a string and parsed with
+// parse.
+func parseDateValue(val stick.Value, parse func(string) (time.Time, error)) (time.Time, error) {
+	switch v := val.(type) {
+	case time.Time:
+		return v, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		sec, frac := math.Modf(stick.CoerceNumber(v))
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
 	}
-	return t, nil
+	return parse(stick.CoerceString(val))
+}
+
+// coerceTime converts val into a time.Time like parseDateValue, parsing
+// strings as a MariaDB datetime.
+func coerceTime(val stick.Value) (time.Time, error) {
+	return parseDateValue(val, func(in string) (time.Time, error) {
+		return convertMariaDBDateTime(in, time.UTC)
+	})
 }
 
+// filterDateModify takes one argument, a relative modifier such as "+1 day",
+// "-2 hours" or "+1 week 3 days", and returns the date val with the
+// modification applied as a time.Time. Supported units are second, minute,
+// hour, day, week, month and year, in singular or plural form. Value nil is
+// returned if val is not a date or the modifier is invalid.
 func filterDateModify(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	if len(args) < 1 {
+		// TODO: Report error
+		return nil
+	}
+	t, err := coerceTime(val)
+	if err != nil {
+		// TODO: Report error
+		return nil
+	}
+	fields := strings.Fields(strings.ToLower(stick.CoerceString(args[0])))
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		// TODO: Report error
+		return nil
+	}
+	for i := 0; i < len(fields); i += 2 {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			// TODO: Report error
+			return nil
+		}
+		switch strings.TrimSuffix(fields[i+1], "s") {
+		case "sec", "second":
+			t = t.Add(time.Duration(n) * time.Second)
+		case "min", "minute":
+			t = t.Add(time.Duration(n) * time.Minute)
+		case "hour":
+			t = t.Add(time.Duration(n) * time.Hour)
+		case "day":
+			t = t.AddDate(0, 0, n)
+		case "week":
+			t = t.AddDate(0, 0, 7*n)
+		case "month":
+			t = t.AddDate(0, n, 0)
+		case "year":
+			t = t.AddDate(n, 0, 0)
+		default:
+			// TODO: Report error
+			return nil
+		}
+	}
+	return t
 }
 
 // filterDefault takes one argument, the default value. If val is empty,
-// the default value will be returned.
+// the default value will be returned. An optional second argument, when
+// true, causes strings consisting only of whitespace to be considered empty.
 func filterDefault(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
 	var d stick.Value
-	if len(args) > 0 {
+	trim := false
+	if l := len(args); l > 0 {
 		d = args[0]
+		if l > 1 {
+			trim = stick.CoerceBool(args[1])
+		}
+	}
+	s := stick.CoerceString(val)
+	if trim {
+		s = strings.TrimSpace(s)
 	}
-	if stick.CoerceString(val) == "" {
+	if s == "" {
 		return d
 	}
 	return val
@@ -305,9 +643,156 @@ func filterFirst(ctx stick.Context, val stick.Value, args ...stick.Value) stick.
 	return nil
 }
 
+// filterFormat returns the format string val with the arguments substituted,
+// like PHP's sprintf. Conversion specifications such as "%s", "%d", "%05.2f",
+// "%-8s", "%'*10s" and positional "%1$s" are supported, and each argument is
+// coerced to suit its specifier.
 func filterFormat(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	format := stick.CoerceString(val)
+	out := &bytes.Buffer{}
+	next := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+		spec, size := parseFormatSpec(format[i+1:])
+		if size == 0 {
+			// Not a valid specification, output as-is.
+			out.WriteByte('%')
+			continue
+		}
+		i += size
+		if spec.verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		var arg stick.Value
+		argNum := next
+		if spec.argNum > 0 {
+			argNum = spec.argNum - 1
+		} else {
+			next++
+		}
+		if argNum < len(args) {
+			arg = args[argNum]
+		}
+		out.WriteString(spec.format(arg))
+	}
+	return out.String()
+}
+
+// formatSpec is a parsed PHP sprintf conversion specification.
+type formatSpec struct {
+	argNum    int
+	flags     string
+	pad       rune
+	width     string
+	precision string
+	verb      byte
+}
+
+// parseFormatSpec parses the conversion specification at the start of s,
+// which follows a "%". It returns the specification and the number of bytes
+// consumed, or 0 if s does not start with a valid specification.
+func parseFormatSpec(s string) (formatSpec, int) {
+	spec := formatSpec{pad: ' '}
+	i := 0
+	digits := func() string {
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		return s[start:i]
+	}
+	if n := digits(); n != "" {
+		if i < len(s) && s[i] == '$' {
+			spec.argNum, _ = strconv.Atoi(n)
+			i++
+		} else {
+			i -= len(n)
+		}
+	}
+	for i < len(s) {
+		switch c := s[i]; c {
+		case '-', '+':
+			spec.flags += string(c)
+			i++
+			continue
+		case '0', ' ':
+			spec.pad = rune(c)
+			i++
+			continue
+		case '\'':
+			if i+1 < len(s) {
+				r, size := utf8.DecodeRuneInString(s[i+1:])
+				spec.pad = r
+				i += 1 + size
+				continue
+			}
+		}
+		break
+	}
+	spec.width = digits()
+	if i < len(s) && s[i] == '.' {
+		i++
+		spec.precision = digits()
+		if spec.precision == "" {
+			spec.precision = "0"
+		}
+	}
+	if i >= len(s) || !strings.ContainsRune("%bcdeEfFgGosuxX", rune(s[i])) {
+		return spec, 0
+	}
+	spec.verb = s[i]
+	return spec, i + 1
+}
+
+// format returns arg formatted according to the specification.
+func (spec formatSpec) format(arg stick.Value) string {
+	verb := string(spec.verb)
+	var v interface{}
+	switch spec.verb {
+	case 'd', 'u':
+		verb = "d"
+		v = int64(stick.CoerceNumber(arg))
+	case 'b', 'o', 'x', 'X':
+		v = int64(stick.CoerceNumber(arg))
+	case 'c':
+		return string(rune(stick.CoerceNumber(arg)))
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		if spec.verb == 'F' {
+			verb = "f"
+		}
+		if spec.precision == "" && (spec.verb == 'e' || spec.verb == 'E' || spec.verb == 'f' || spec.verb == 'F') {
+			spec.precision = "6"
+		}
+		v = stick.CoerceNumber(arg)
+	default:
+		v = stick.CoerceString(arg)
+	}
+	goFormat := "%" + strings.Replace(spec.flags, "-", "", -1)
+	if spec.precision != "" {
+		goFormat += "." + spec.precision
+	}
+	res := fmt.Sprintf(goFormat+verb, v)
+	width, _ := strconv.Atoi(spec.width)
+	padding := width - utf8.RuneCountInString(res)
+	if padding <= 0 {
+		return res
+	}
+	pad := strings.Repeat(string(spec.pad), padding)
+	if strings.Contains(spec.flags, "-") {
+		if spec.pad == '0' {
+			// PHP never pads with zeroes on the right.
+			pad = strings.Repeat(" ", padding)
+		}
+		return res + pad
+	}
+	if spec.pad == '0' && (strings.HasPrefix(res, "-") || strings.HasPrefix(res, "+")) {
+		return res[:1] + pad + res[1:]
+	}
+	return pad + res
 }
 
 func filterJoin(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
@@ -329,19 +814,75 @@ func filterJoin(ctx stick.Context, val stick.Value, args ...stick.Value) stick.V
 	return strings.Join(slice, separator)
 }
 
+// filterJSONEncode takes one optional argument, the number of spaces to
+// indent by, and returns val encoded as JSON. If the indent is greater than
+// zero, the output is pretty-printed, like PHP's JSON_PRETTY_PRINT. Map keys
+// are emitted in sorted order and nil is encoded as null. An empty string is
+// returned if val cannot be encoded.
 func filterJSONEncode(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	indent := 0
+	if len(args) >= 1 {
+		indent = int(stick.CoerceNumber(args[0]))
+	}
+	var res []byte
+	var err error
+	if indent > 0 {
+		res, err = json.MarshalIndent(jsonValue(val), "", strings.Repeat(" ", indent))
+	} else {
+		res, err = json.Marshal(jsonValue(val))
+	}
+	if err != nil {
+		// TODO: Report error
+		return ""
+	}
+	return string(res)
 }
 
+// filterKeys takes no arguments and returns the keys of val. The keys of a
+// map are returned as strings in sorted order, so that output is stable, and
+// slices and arrays return their indexes. Any other value returns an empty
+// list.
 func filterKeys(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	keys := []stick.Value{}
+	if stick.IsMap(val) {
+		for _, k := range sortedKeys(copyMap(val)) {
+			keys = append(keys, k)
+		}
+		return keys
+	}
+	if stick.IsArray(val) {
+		l, _ := stick.Len(val)
+		for i := 0; i < l; i++ {
+			keys = append(keys, i)
+		}
+	}
+	return keys
 }
 
+// filterLast takes no arguments and returns the last element of a slice or
+// array, or the last character of a string. Empty values return nil. Maps
+// always return nil, as their iteration order is undefined and so they have
+// no last element.
 func filterLast(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	if stick.IsArray(val) {
+		arr := reflect.Indirect(reflect.ValueOf(val))
+		if arr.Len() == 0 {
+			return nil
+		}
+		return arr.Index(arr.Len() - 1).Interface()
+	}
+
+	if stick.IsMap(val) {
+		// TODO: Trigger runtime error, Golang randomises map keys so getting the "Last" does not make sense
+		return nil
+	}
+
+	if s := stick.CoerceString(val); s != "" {
+		r, _ := utf8.DecodeLastRuneInString(s)
+		return string(r)
+	}
+
+	return nil
 }
 
 // filterLength returns the length of val.
@@ -354,9 +895,15 @@ func filterLength(ctx stick.Context, val stick.Value, args ...stick.Value) stick
 	return l
 }
 
-// filterLower returns val transformed to lower-case.
+// filterLower takes one optional argument, the locale, and returns val
+// transformed to lower-case. If no locale is given, the "locale" metadata
+// attribute of the context is used.
 func filterLower(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	return strings.ToLower(stick.CoerceString(val))
+	tag := caseLanguage(ctx, args...)
+	if tag == language.Und {
+		return strings.ToLower(stick.CoerceString(val))
+	}
+	return cases.Lower(tag).String(stick.CoerceString(val))
 }
 
 func filterMerge(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
@@ -383,59 +930,448 @@ func filterMerge(ctx stick.Context, val stick.Value, args ...stick.Value) stick.
 	return out
 }
 
+// filterNL2BR takes no arguments and returns val with "<br />" inserted
+// before each newline, whether "\r\n", "\n" or "\r". Like Twig, val is HTML
+// escaped first unless already safe, and the result is marked safe for HTML.
 func filterNL2BR(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	s := escapeHTMLValue(val)
+	out := &bytes.Buffer{}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\r':
+			out.WriteString("<br />\r")
+			if i+1 < len(s) && s[i+1] == '\n' {
+				out.WriteByte('\n')
+				i++
+			}
+		case '\n':
+			out.WriteString("<br />\n")
+		default:
+			out.WriteByte(s[i])
+		}
+	}
+	return stick.NewSafeValue(out.String(), "html")
 }
 
+// filterNumberFormat takes three optional arguments: the number of decimals
+// (defaults to 0), the decimal point (defaults to "."), and the thousands
+// separator (defaults to ","). Like PHP's number_format, val is rounded half
+// away from zero to the number of decimals, using the same pre-rounding as
+// round, and its integer part is grouped in threes. Value val will be coerced into a number.
 func filterNumberFormat(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	decimals := 0
+	point, separator := ".", ","
+	if l := len(args); l >= 1 {
+		decimals = int(stick.CoerceNumber(args[0]))
+		if l >= 2 {
+			point = stick.CoerceString(args[1])
+			if l >= 3 {
+				separator = stick.CoerceString(args[2])
+			}
+		}
+	}
+	if decimals < 0 {
+		decimals = 0
+	}
+	n := stick.CoerceNumber(val)
+	abs := roundPrecision(math.Abs(n), decimals, math.Round)
+	s := strconv.FormatFloat(abs, 'f', decimals, 64)
+	intPart, fracPart := s, ""
+	if p := strings.Index(s, "."); p >= 0 {
+		intPart, fracPart = s[:p], s[p+1:]
+	}
+	out := &bytes.Buffer{}
+	if n < 0 && abs != 0 {
+		out.WriteString("-")
+	}
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			out.WriteString(separator)
+		}
+		out.WriteRune(c)
+	}
+	if fracPart != "" {
+		out.WriteString(point + fracPart)
+	}
+	return out.String()
 }
 
+// filterRaw takes no arguments and returns val as a stick.SafeString, so
+// it is not escaped when output as HTML. Printing the result of raw directly
+// is never escaped, whatever the autoescape strategy.
 func filterRaw(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	if s, ok := val.(stick.SafeString); ok {
+		return s
+	}
+	return stick.SafeString(stick.CoerceString(val))
 }
 
+// filterReplace takes either one argument, a map of search strings to their
+// replacements, or two arguments, a search string and its replacement, and
+// returns val with the replacements applied. Like PHP's strtr, the longest
+// search string matching at any position wins and replaced text is not
+// searched again.
 func filterReplace(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	s := stick.CoerceString(val)
+	pairs := make(map[string]stick.Value)
+	switch {
+	case len(args) >= 2:
+		pairs[stick.CoerceString(args[0])] = args[1]
+	case len(args) == 1 && stick.IsMap(args[0]):
+		pairs = copyMap(args[0])
+	default:
+		return s
+	}
+	keys := sortedKeys(pairs)
+	sort.SliceStable(keys, func(i, j int) bool {
+		return len(keys[i]) > len(keys[j])
+	})
+	var oldnew []string
+	for _, k := range keys {
+		if k == "" {
+			continue
+		}
+		oldnew = append(oldnew, k, stick.CoerceString(pairs[k]))
+	}
+	return strings.NewReplacer(oldnew...).Replace(s)
 }
 
+// filterReverse takes no arguments and returns val reversed. Slices and
+// arrays are returned as a new, reversed list, leaving val untouched, and
+// strings are reversed by character. Maps have no order in Go, so they are
+// returned unchanged.
 func filterReverse(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	if stick.IsMap(val) {
+		return val
+	}
+
+	if stick.IsArray(val) {
+		values, _ := iterableValues(val)
+		for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+			values[i], values[j] = values[j], values[i]
+		}
+		return values
+	}
+
+	r := []rune(stick.CoerceString(val))
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
 }
 
+// filterRound takes two optional arguments, the precision (defaults to 0)
+// and the rounding method "common" (default), "ceil" or "floor", and returns
+// val rounded. A negative precision rounds to tens, hundreds, and so on.
+// Value val will be coerced into a number.
 func filterRound(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	precision := 0
+	method := "common"
+	if l := len(args); l >= 1 {
+		precision = int(stick.CoerceNumber(args[0]))
+		if l >= 2 {
+			method = stick.CoerceString(args[1])
+		}
+	}
+	round := math.Round
+	switch method {
+	case "ceil":
+		round = math.Ceil
+	case "floor":
+		round = math.Floor
+	case "common":
+	default:
+		// TODO: Report error, unknown rounding method.
+		return nil
+	}
+	return roundPrecision(stick.CoerceNumber(val), precision, round)
+}
+
+// roundPrecision returns n rounded with round to precision decimal places.
+// Like PHP, the scaled value is first rounded to 15 significant digits, so
+// representation errors such as 1.005 being stored as 1.00499... do not
+// change the result.
+func roundPrecision(n float64, precision int, round func(float64) float64) float64 {
+	scale := math.Pow(10, math.Abs(float64(precision)))
+	scaled := n * scale
+	if precision < 0 {
+		scaled = n / scale
+	}
+	if !math.IsInf(scaled, 0) && !math.IsNaN(scaled) {
+		scaled, _ = strconv.ParseFloat(strconv.FormatFloat(scaled, 'g', 15, 64), 64)
+	}
+	if precision < 0 {
+		return round(scaled) * scale
+	}
+	return round(scaled) / scale
 }
 
+// filterSlice takes up to three arguments, start, length and preserveKeys,
+// and returns the portion of val beginning at start. A negative start counts
+// from the end of val. If length is omitted or nil the slice runs to the end
+// of val; a negative length stops that many elements from the end. Out of
+// range values are clamped.
+//
+// Strings are sliced by character. Arrays are returned as a list, or as a
+// map of original index to value if preserveKeys is true. Maps are sliced in
+// sorted key order and always keep their keys.
 func filterSlice(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	start := 0
+	var length stick.Value
+	preserveKeys := false
+	if l := len(args); l >= 1 {
+		start = int(stick.CoerceNumber(args[0]))
+		if l >= 2 {
+			length = args[1]
+			if l >= 3 {
+				preserveKeys = stick.CoerceBool(args[2])
+			}
+		}
+	}
+	if stick.IsMap(val) {
+		m := copyMap(val)
+		keys := sortedKeys(m)
+		from, to := sliceBounds(start, length, len(keys))
+		res := make(map[string]stick.Value, to-from)
+		for _, k := range keys[from:to] {
+			res[k] = m[k]
+		}
+		return res
+	}
+	if stick.IsArray(val) {
+		values, _ := iterableValues(val)
+		from, to := sliceBounds(start, length, len(values))
+		if !preserveKeys {
+			return values[from:to]
+		}
+		res := make(map[int]stick.Value, to-from)
+		for i := from; i < to; i++ {
+			res[i] = values[i]
+		}
+		return res
+	}
+	runes := []rune(stick.CoerceString(val))
+	from, to := sliceBounds(start, length, len(runes))
+	return string(runes[from:to])
 }
 
+// sliceBounds returns the bounds of the slice of a sequence of length l
+// described by start and length, following PHP's array_slice.
+func sliceBounds(start int, length stick.Value, l int) (int, int) {
+	if start < 0 {
+		start += l
+	}
+	from := clampIndex(start, l)
+	if from < 0 {
+		from = 0
+	}
+	to := l
+	if length != nil {
+		n := int(stick.CoerceNumber(length))
+		if n < 0 {
+			to = l + n
+		} else {
+			to = from + n
+		}
+	}
+	if to > l {
+		to = l
+	}
+	if to < from {
+		to = from
+	}
+	return from, to
+}
+
+// filterSort takes one optional argument and returns the elements of val as
+// a new, sorted list. Numeric values are compared numerically and anything
+// else as strings. The argument may be "desc" to reverse the order, or a
+// comparator: a Go func(a, b stick.Value) int or a stick.Func returning a
+// number less than, equal to or greater than zero. Maps have no order in Go,
+// so their values are returned as a sorted list and the keys are dropped.
 func filterSort(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	cmp := compareValues
+	if len(args) >= 1 {
+		switch fn := args[0].(type) {
+		case func(a, b stick.Value) int:
+			cmp = fn
+		case stick.Func:
+			cmp = func(a, b stick.Value) int { return numberSign(stick.CoerceNumber(fn(ctx, a, b))) }
+		case func(stick.Context, ...stick.Value) stick.Value:
+			cmp = func(a, b stick.Value) int { return numberSign(stick.CoerceNumber(fn(ctx, a, b))) }
+		default:
+			if strings.ToLower(stick.CoerceString(fn)) == "desc" {
+				cmp = func(a, b stick.Value) int { return compareValues(b, a) }
+			}
+		}
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return cmp(values[i], values[j]) < 0
+	})
+	return values
+}
+
+// numberSign returns -1, 0 or 1 depending on the sign of n.
+func numberSign(n float64) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// compareValues returns -1, 0 or 1 depending on whether a sorts before,
+// together with, or after b. Numeric values sort before anything else and
+// are compared as numbers, anything else is compared as strings, so the
+// order is the same regardless of the order of the input.
+func compareValues(a, b stick.Value) int {
+	an, bn := isNumeric(a), isNumeric(b)
+	switch {
+	case an && bn:
+		return numberSign(stick.CoerceNumber(a) - stick.CoerceNumber(b))
+	case an:
+		return -1
+	case bn:
+		return 1
+	}
+	return strings.Compare(stick.CoerceString(a), stick.CoerceString(b))
+}
+
+// isNumeric returns true if val is a number or a string containing one.
+func isNumeric(val stick.Value) bool {
+	switch v := val.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, stick.Number:
+		return true
+	case string:
+		_, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return err == nil
+	}
+	return false
 }
 
+// filterSplit takes one argument, the delimiter, and an optional limit, and
+// returns val split into a list of strings. With a positive limit, at most
+// limit elements are returned, the last containing the rest of the string.
+// If the delimiter is empty, val is split into chunks of limit characters,
+// defaulting to single characters.
 func filterSplit(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	s := stick.CoerceString(val)
+	delimiter := ""
+	limit := 0
+	if l := len(args); l >= 1 {
+		delimiter = stick.CoerceString(args[0])
+		if l >= 2 {
+			limit = int(stick.CoerceNumber(args[1]))
+		}
+	}
+	out := []stick.Value{}
+	if delimiter == "" {
+		if limit <= 0 {
+			limit = 1
+		}
+		r := []rune(s)
+		for i := 0; i < len(r); i += limit {
+			end := i + limit
+			if end > len(r) {
+				end = len(r)
+			}
+			out = append(out, string(r[i:end]))
+		}
+		return out
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	for _, part := range strings.SplitN(s, delimiter, limit) {
+		out = append(out, part)
+	}
+	return out
 }
 
+// filterStripTags takes one optional argument, the allowed tags, and returns
+// val with HTML tags and comments removed. Allowed tags may be given as a
+// string such as "<a><br>" or as a list of tag names, and are kept along with
+// their attributes. The contents of script and style elements are removed
+// unless the element is allowed.
 func filterStripTags(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	// TODO: Implement Me
-	return val
+	allowed := make(map[string]bool)
+	if len(args) >= 1 {
+		if stick.IsIterable(args[0]) {
+			stick.Iterate(args[0], func(k, v stick.Value, l stick.Loop) (bool, error) {
+				allowed[strings.ToLower(strings.Trim(stick.CoerceString(v), "<>/ "))] = true
+				return false, nil
+			})
+		} else {
+			for _, name := range strings.Split(stick.CoerceString(args[0]), "<") {
+				if name = strings.ToLower(strings.Trim(name, "<>/ ")); name != "" {
+					allowed[name] = true
+				}
+			}
+		}
+	}
+	out := &bytes.Buffer{}
+	skip := ""
+	z := html.NewTokenizer(strings.NewReader(stick.CoerceString(val)))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return out.String()
+		}
+		raw := z.Raw()
+		name, _ := z.TagName()
+		tag := string(name)
+		switch tt {
+		case html.TextToken:
+			if skip == "" {
+				out.Write(raw)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if allowed[tag] {
+				out.Write(raw)
+			} else if tt == html.StartTagToken && (tag == "script" || tag == "style") {
+				skip = tag
+			}
+		case html.EndTagToken:
+			if allowed[tag] {
+				out.Write(raw)
+			} else if tag == skip {
+				skip = ""
+			}
+		}
+	}
 }
 
-// filterTitle returns val with the first character of each word capitalized.
+// filterTitle takes one optional argument, the locale, and returns val with
+// the first character of each word capitalized. If no locale is given, the
+// "locale" metadata attribute of the context is used.
 func filterTitle(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	return strings.Title(stick.CoerceString(val))
+	return cases.Title(caseLanguage(ctx, args...), cases.NoLower).String(stick.CoerceString(val))
+}
+
+// caseLanguage returns the language used for case mapping. The first
+// argument takes precedence over the "locale" metadata attribute of the
+// context. The root locale is returned if neither is set or valid.
+func caseLanguage(ctx stick.Context, args ...stick.Value) language.Tag {
+	locale := ""
+	if len(args) >= 1 {
+		locale = stick.CoerceString(args[0])
+	} else if ctx != nil {
+		locale, _ = ctx.Meta().Get("locale")
+	}
+	if locale == "" {
+		return language.Und
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return language.Und
+	}
+	return tag
 }
 
 // filterTrim returns val with whitespace trimmed on both left and ride sides.
@@ -443,9 +1379,15 @@ func filterTrim(ctx stick.Context, val stick.Value, args ...stick.Value) stick.V
 	return strings.TrimSpace(stick.CoerceString(val))
 }
 
-// filterUpper returns val in upper-case.
+// filterUpper takes one optional argument, the locale, and returns val in
+// upper-case. If no locale is given, the "locale" metadata attribute of the
+// context is used.
 func filterUpper(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
-	return strings.ToUpper(stick.CoerceString(val))
+	tag := caseLanguage(ctx, args...)
+	if tag == language.Und {
+		return strings.ToUpper(stick.CoerceString(val))
+	}
+	return cases.Upper(tag).String(stick.CoerceString(val))
 }
 
 func filterURLEncode(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
@@ -453,10 +1395,16 @@ func filterURLEncode(ctx stick.Context, val stick.Value, args ...stick.Value) st
 	return url.PathEscape(stick.CoerceString(val))
 }
 
+// filterGet takes one argument, a key, and returns the element of val
+// stored under that key. Lists are indexed from 0, like first and Twig's
+// array[key], and a negative index counts from the end, so -1 is the last
+// element. Lists are only indexed by numbers, so a string key on a list
+// returns nil. Value nil is returned if the key does not exist.
 func filterGet(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
 	// by polakto
 	var intKey int
 	var strKey string
+	isIndex := false
 
 	if len(args) != 1 {
 		return nil
@@ -464,21 +1412,26 @@ func filterGet(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Va
 	switch args[0].(type) {
 	case string:
 		strKey = args[0].(string)
-	case float64:
-		intKey = int(args[0].(float64))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		intKey = int(stick.CoerceNumber(args[0]))
+		isIndex = true
 	default:
 		return nil
 	}
 
 	switch val.(type) {
 	case []stick.Value:
-		if intKey == 0 {
+		if !isIndex {
 			return nil
 		}
-		if intKey > len(val.([]stick.Value)) {
+		list := val.([]stick.Value)
+		if intKey < 0 {
+			intKey += len(list)
+		}
+		if intKey < 0 || intKey >= len(list) {
 			return nil
 		}
-		return val.([]stick.Value)[intKey-1]
+		return list[intKey]
 	case map[string]stick.Value:
 		if strKey == "" {
 			return nil
@@ -493,3 +1446,2336 @@ func filterGet(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Va
 		return nil
 	}
 }
+
+// filterTake takes one argument, n, and returns the first n elements of val.
+// A negative n returns the last n elements instead. Value n is clamped to the
+// length of val.
+func filterTake(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	n := 0
+	if len(args) >= 1 {
+		n = clampIndex(int(stick.CoerceNumber(args[0])), len(values))
+	}
+	if n < 0 {
+		return values[len(values)+n:]
+	}
+	return values[:n]
+}
+
+// filterDrop takes one argument, n, and returns val without its first n
+// elements. A negative n drops the last n elements instead. Value n is clamped
+// to the length of val.
+func filterDrop(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	n := 0
+	if len(args) >= 1 {
+		n = clampIndex(int(stick.CoerceNumber(args[0])), len(values))
+	}
+	if n < 0 {
+		return values[:len(values)+n]
+	}
+	return values[n:]
+}
+
+// iterableValues returns the values contained in val as a new slice. The
+// second return value is false if val is not iterable.
+func iterableValues(val stick.Value) ([]stick.Value, bool) {
+	if !stick.IsIterable(val) {
+		return nil, false
+	}
+	values := []stick.Value{}
+	_, err := stick.Iterate(val, func(k, v stick.Value, l stick.Loop) (bool, error) {
+		values = append(values, v)
+		return false, nil
+	})
+	if err != nil {
+		return nil, false
+	}
+	return values, true
+}
+
+// clampIndex limits n to the range [-l, l].
+func clampIndex(n, l int) int {
+	if n > l {
+		return l
+	}
+	if n < -l {
+		return -l
+	}
+	return n
+}
+
+// filterPartition takes one argument, a predicate, and splits val into two
+// lists: the elements for which the predicate holds, and those for which it
+// does not. The result is a two-element slice holding both lists.
+func filterPartition(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 1 {
+		return nil
+	}
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	matched := []stick.Value{}
+	rest := []stick.Value{}
+	for _, v := range values {
+		if callPredicate(ctx, args[0], v) {
+			matched = append(matched, v)
+		} else {
+			rest = append(rest, v)
+		}
+	}
+	return []stick.Value{matched, rest}
+}
+
+// callPredicate applies pred to v and reports the result. The predicate may
+// be a Go function, a stick.Func, a stick.Test, or the name of a Test
+// registered in the Env.
+func callPredicate(ctx stick.Context, pred stick.Value, v stick.Value) bool {
+	switch fn := pred.(type) {
+	case func(stick.Value) bool:
+		return fn(v)
+	case stick.Test:
+		return fn(ctx, v)
+	case func(stick.Context, stick.Value, ...stick.Value) bool:
+		return fn(ctx, v)
+	case stick.Func:
+		return stick.CoerceBool(fn(ctx, v))
+	case func(stick.Context, ...stick.Value) stick.Value:
+		return stick.CoerceBool(fn(ctx, v))
+	case string:
+		if ctx == nil {
+			return false
+		}
+		if test, ok := ctx.Env().Tests[fn]; ok {
+			return test(ctx, v)
+		}
+	}
+	return false
+}
+
+// filterTranspose takes no arguments and returns val, a list of lists, with
+// its rows and columns swapped. Ragged rows are padded with nil so that every
+// resulting row has one element per input row.
+func filterTranspose(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	rows, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	matrix := make([][]stick.Value, len(rows))
+	width := 0
+	for i, row := range rows {
+		cells, ok := iterableValues(row)
+		if !ok {
+			return nil
+		}
+		matrix[i] = cells
+		if len(cells) > width {
+			width = len(cells)
+		}
+	}
+	out := make([]stick.Value, width)
+	for j := 0; j < width; j++ {
+		col := make([]stick.Value, len(matrix))
+		for i, cells := range matrix {
+			if j < len(cells) {
+				col[i] = cells[j]
+			}
+		}
+		out[j] = col
+	}
+	return out
+}
+
+// filterToTree takes three optional arguments: the id field name (defaults to
+// "id"), the parent id field name (defaults to "parent_id"), and the children
+// field name (defaults to "children"). Value val must be a flat list of maps,
+// which is returned as a list of root nodes with their descendants nested
+// under the children field. Nodes with a nil, empty or 0 parent id are roots.
+// Orphaned nodes, whose parent cannot be found, are treated as roots as well,
+// and so is the node that would close a cycle of parent ids, such as a node
+// whose parent is its own child. Elements of val that are not maps are
+// skipped.
+func filterToTree(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	idField, parentField, childrenField := "id", "parent_id", "children"
+	if l := len(args); l >= 1 {
+		idField = stick.CoerceString(args[0])
+		if l >= 2 {
+			parentField = stick.CoerceString(args[1])
+			if l >= 3 {
+				childrenField = stick.CoerceString(args[2])
+			}
+		}
+	}
+	items, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	var nodes []map[string]stick.Value
+	byID := make(map[string]int)
+	for _, item := range items {
+		if !stick.IsMap(item) {
+			continue
+		}
+		node := copyMap(item)
+		node[childrenField] = []stick.Value{}
+		byID[stick.CoerceString(node[idField])] = len(nodes)
+		nodes = append(nodes, node)
+	}
+	// parents holds the index of the parent each node has been attached to,
+	// or -1, and is used to detect cycles before attaching a node.
+	parents := make([]int, len(nodes))
+	for i := range parents {
+		parents[i] = -1
+	}
+	roots := []stick.Value{}
+	for i, node := range nodes {
+		parentID := stick.CoerceString(node[parentField])
+		p, ok := byID[parentID]
+		if parentID == "" || parentID == "0" || !ok || createsTreeCycle(parents, i, p) {
+			roots = append(roots, node)
+			continue
+		}
+		parents[i] = p
+		parent := nodes[p]
+		parent[childrenField] = append(parent[childrenField].([]stick.Value), node)
+	}
+	return roots
+}
+
+// createsTreeCycle reports whether attaching node to parent would make node
+// its own ancestor, given the parents attached so far.
+func createsTreeCycle(parents []int, node, parent int) bool {
+	for p := parent; p >= 0; p = parents[p] {
+		if p == node {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]stick.Value) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// copyMap returns a shallow copy of val with its keys coerced to strings. An
+// empty map is returned if val is not a map.
+func copyMap(val stick.Value) map[string]stick.Value {
+	out := make(map[string]stick.Value)
+	if !stick.IsMap(val) {
+		return out
+	}
+	stick.Iterate(val, func(k, v stick.Value, l stick.Loop) (bool, error) {
+		out[stick.CoerceString(k)] = v
+		return false, nil
+	})
+	return out
+}
+
+// filterFlattenTree takes two optional arguments: the children field name
+// (defaults to "children") and the depth field name (defaults to "depth").
+// Value val must be a list of nested maps, such as the output of to_tree. The
+// nodes are returned as a flat list in depth-first pre-order, each with its
+// depth, starting at 0 for the root nodes, added under the depth field.
+func filterFlattenTree(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	childrenField, depthField := "children", "depth"
+	if l := len(args); l >= 1 {
+		childrenField = stick.CoerceString(args[0])
+		if l >= 2 {
+			depthField = stick.CoerceString(args[1])
+		}
+	}
+	if !stick.IsIterable(val) {
+		return nil
+	}
+	out := []stick.Value{}
+	var walk func(nodes stick.Value, depth int)
+	walk = func(nodes stick.Value, depth int) {
+		stick.Iterate(nodes, func(k, v stick.Value, l stick.Loop) (bool, error) {
+			node := copyMap(v)
+			node[depthField] = depth
+			out = append(out, node)
+			if children, ok := node[childrenField]; ok && stick.IsIterable(children) {
+				walk(children, depth+1)
+			}
+			return false, nil
+		})
+	}
+	walk(val, 0)
+	return out
+}
+
+// filterWrap takes no arguments and returns val as a list. Slices and arrays
+// are returned unchanged, nil becomes an empty list, and any other value,
+// including a map, is wrapped in a single-element list.
+func filterWrap(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if val == nil {
+		return []stick.Value{}
+	}
+	if stick.IsArray(val) {
+		return val
+	}
+	return []stick.Value{val}
+}
+
+// filterUnwrap takes no arguments and returns the sole element of val if val
+// is a single-element slice or array. Empty and multi-element lists return
+// nil, and any other value is returned unchanged.
+func filterUnwrap(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if !stick.IsArray(val) {
+		return val
+	}
+	arr := reflect.Indirect(reflect.ValueOf(val))
+	if arr.Len() != 1 {
+		// TODO: Report error
+		return nil
+	}
+	return arr.Index(0).Interface()
+}
+
+// filterValid takes one argument, the name of a format, and returns true if
+// val is a string matching that format. Supported formats are "email", "url",
+// "ipv4", "ipv6", and "uuid". IPv4-mapped IPv6 addresses such as
+// "::ffff:1.2.3.4" are IPv4 addresses, and IPv6 addresses may carry a zone
+// such as "%eth0". Unknown formats never match.
+func filterValid(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 1 {
+		return false
+	}
+	s := stick.CoerceString(val)
+	switch strings.ToLower(stick.CoerceString(args[0])) {
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "url":
+		u, err := url.ParseRequestURI(s)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil
+	case "ipv6":
+		if p := strings.IndexByte(s, '%'); p >= 0 && p < len(s)-1 {
+			s = s[:p]
+		}
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() == nil && ip.To16() != nil
+	case "uuid":
+		return isUUID(s)
+	}
+	// TODO: Report error, unknown format.
+	return false
+}
+
+// isUUID returns true if s is a UUID in its canonical, hyphenated form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// filterFraction takes two optional arguments, the maximum denominator
+// (defaults to 100) and the tolerance (defaults to 1e-6), and returns val as
+// the nearest simple fraction, such as "3/4". If no fraction within the
+// tolerance exists, val is returned as a number.
+func filterFraction(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	maxDenominator := 100
+	tolerance := 1e-6
+	if l := len(args); l >= 1 {
+		maxDenominator = int(stick.CoerceNumber(args[0]))
+		if l >= 2 {
+			tolerance = stick.CoerceNumber(args[1])
+		}
+	}
+	n := stick.CoerceNumber(val)
+	bestNum, bestDen := 0.0, 0
+	bestErr := math.Inf(1)
+	for den := 1; den <= maxDenominator; den++ {
+		num := math.Round(n * float64(den))
+		if err := math.Abs(n - num/float64(den)); err < bestErr {
+			bestNum, bestDen, bestErr = num, den, err
+		}
+	}
+	if bestDen == 0 || bestErr > tolerance {
+		return n
+	}
+	if bestNum == 0 {
+		// Tiny negative values round to -0.
+		return "0"
+	}
+	if bestDen == 1 {
+		return fmt.Sprintf("%.0f", bestNum)
+	}
+	return fmt.Sprintf("%.0f/%d", bestNum, bestDen)
+}
+
+// filterVersionCompare takes one argument, the version to compare against,
+// and an optional operator. Versions are compared like PHP's version_compare,
+// so "1.10.0" is newer than "1.2.0" and pre-release suffixes such as "beta"
+// or "rc" sort before the release itself. Without an operator, -1, 0 or 1 is
+// returned. With an operator such as "<", ">=" or "ne", a boolean is
+// returned instead.
+func filterVersionCompare(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 1 {
+		return nil
+	}
+	res := compareVersions(stick.CoerceString(val), stick.CoerceString(args[0]))
+	if len(args) < 2 {
+		return res
+	}
+	switch stick.CoerceString(args[1]) {
+	case "<", "lt":
+		return res < 0
+	case "<=", "le":
+		return res <= 0
+	case ">", "gt":
+		return res > 0
+	case ">=", "ge":
+		return res >= 0
+	case "==", "eq":
+		return res == 0
+	case "!=", "<>", "ne":
+		return res != 0
+	}
+	// TODO: Report error, unknown operator.
+	return nil
+}
+
+// versionSpecialOrder ranks the special version strings understood by
+// PHP's version_compare. Numbers rank as "#".
+var versionSpecialOrder = map[string]int{
+	"dev":   0,
+	"alpha": 1,
+	"a":     1,
+	"beta":  2,
+	"b":     2,
+	"rc":    3,
+	"#":     4,
+	"pl":    5,
+	"p":     5,
+}
+
+// canonicalVersion splits a version string into its parts, treating "-",
+// "_" and "+" as separators and splitting between digits and letters.
+func canonicalVersion(v string) []string {
+	var parts []string
+	var curr []rune
+	isDigit := func(r rune) bool { return r >= '0' && r <= '9' }
+	for _, r := range v {
+		if r == '.' || r == '-' || r == '_' || r == '+' {
+			if len(curr) > 0 {
+				parts = append(parts, string(curr))
+			}
+			curr = nil
+			continue
+		}
+		if len(curr) > 0 && isDigit(curr[len(curr)-1]) != isDigit(r) {
+			parts = append(parts, string(curr))
+			curr = nil
+		}
+		curr = append(curr, r)
+	}
+	if len(curr) > 0 {
+		parts = append(parts, string(curr))
+	}
+	return parts
+}
+
+// versionPartRank returns the rank of a non-numeric version part. Unknown
+// strings rank below "dev".
+func versionPartRank(part string) int {
+	if _, err := strconv.Atoi(part); err == nil {
+		return versionSpecialOrder["#"]
+	}
+	if r, ok := versionSpecialOrder[strings.ToLower(part)]; ok {
+		return r
+	}
+	return -1
+}
+
+// compareVersions returns -1, 0 or 1 depending on whether a is older than,
+// equal to, or newer than b.
+func compareVersions(a, b string) int {
+	pa, pb := canonicalVersion(a), canonicalVersion(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var ra, rb int
+		switch {
+		case i >= len(pa):
+			ra, rb = versionSpecialOrder["#"], versionPartRank(pb[i])
+			if rb == ra {
+				return -1
+			}
+		case i >= len(pb):
+			ra, rb = versionPartRank(pa[i]), versionSpecialOrder["#"]
+			if ra == rb {
+				return 1
+			}
+		default:
+			na, errA := strconv.Atoi(pa[i])
+			nb, errB := strconv.Atoi(pb[i])
+			if errA == nil && errB == nil {
+				ra, rb = na, nb
+			} else {
+				ra, rb = versionPartRank(pa[i]), versionPartRank(pb[i])
+			}
+		}
+		if ra < rb {
+			return -1
+		}
+		if ra > rb {
+			return 1
+		}
+	}
+	return 0
+}
+
+// filterSummarize takes three optional arguments: the number of visible
+// elements (defaults to 3), the separator (defaults to ", "), and the suffix
+// (defaults to " and %d more"). The visible elements of val are joined by the
+// separator and, if any elements were left out, followed by the suffix with
+// the number of hidden elements substituted for every %d. The suffix is not a
+// format string; anything other than %d is output as-is.
+func filterSummarize(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	visible := 3
+	separator := ", "
+	suffix := " and %d more"
+	if l := len(args); l >= 1 {
+		visible = int(stick.CoerceNumber(args[0]))
+		if l >= 2 {
+			separator = stick.CoerceString(args[1])
+			if l >= 3 {
+				suffix = stick.CoerceString(args[2])
+			}
+		}
+	}
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	if visible < 0 {
+		visible = 0
+	}
+	hidden := 0
+	if len(values) > visible {
+		hidden = len(values) - visible
+		values = values[:visible]
+	}
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = stick.CoerceString(v)
+	}
+	res := strings.Join(parts, separator)
+	if hidden > 0 {
+		res += strings.Replace(suffix, "%d", strconv.Itoa(hidden), -1)
+	}
+	return res
+}
+
+// filterReorder takes one argument, a list of keys, and returns the values of
+// the map val in the order given by the keys. Keys missing from val are
+// skipped.
+func filterReorder(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 1 || !stick.IsMap(val) {
+		return nil
+	}
+	keys, ok := iterableValues(args[0])
+	if !ok {
+		return nil
+	}
+	m := copyMap(val)
+	out := []stick.Value{}
+	for _, k := range keys {
+		if v, ok := m[stick.CoerceString(k)]; ok {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// filterFragmentEncode returns val percent-encoded for use as the fragment
+// component of a URL. Unlike url_encode, characters such as "/" and "?" are
+// allowed in a fragment and are left as-is.
+func filterFragmentEncode(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	u := url.URL{Fragment: stick.CoerceString(val)}
+	return u.EscapedFragment()
+}
+
+// filterMailto takes one optional argument, a map of header fields such as
+// "subject", "body" and "cc", and returns a mailto URL for the address val.
+// Each part is percent-encoded and the fields are emitted in sorted order.
+// The URL is then HTML escaped, so it can be used in an href attribute, and
+// the result is marked safe for HTML.
+func filterMailto(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	res := "mailto:" + url.PathEscape(stick.CoerceString(val))
+	if len(args) >= 1 && stick.IsMap(args[0]) {
+		fields := copyMap(args[0])
+		var params []string
+		for _, k := range sortedKeys(fields) {
+			params = append(params, mailtoEscape(k)+"="+mailtoEscape(stick.CoerceString(fields[k])))
+		}
+		if len(params) > 0 {
+			res += "?" + strings.Join(params, "&")
+		}
+	}
+	return stick.NewSafeValue(escape.HTML(res), "html")
+}
+
+// mailtoEscape percent-encodes s for use in a mailto header field. Spaces
+// are encoded as "%20" rather than "+".
+func mailtoEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+// filterCSSStyle takes no arguments and renders the map val of CSS properties
+// as an inline style declaration, such as "color: red; font-size: 12px;".
+// Properties are emitted in sorted order and those with nil or empty values
+// are omitted. Characters that could end a declaration are removed from the
+// values, which are then HTML escaped. Declarations that could run script,
+// such as expression() values, javascript: URLs or behavior properties, are
+// dropped. The result is marked safe for HTML.
+func filterCSSStyle(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if !stick.IsMap(val) {
+		return nil
+	}
+	props := copyMap(val)
+	var decls []string
+	for _, k := range sortedKeys(props) {
+		if props[k] == nil {
+			continue
+		}
+		name := strings.Map(func(r rune) rune {
+			if r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				return r
+			}
+			return -1
+		}, k)
+		value := strings.TrimSpace(strings.Map(func(r rune) rune {
+			if r == ';' || r == '{' || r == '}' {
+				return -1
+			}
+			return r
+		}, stick.CoerceString(props[k])))
+		if name == "" || value == "" || unsafeCSSDeclaration(name, value) {
+			continue
+		}
+		decls = append(decls, name+": "+escape.HTML(value)+";")
+	}
+	return stick.NewSafeValue(strings.Join(decls, " "), "html")
+}
+
+// unsafeCSSPatterns lists the lowercase value fragments that can run script
+// in some browsers.
+var unsafeCSSPatterns = []string{"expression(", "javascript:", "vbscript:"}
+
+// unsafeCSSDeclaration reports whether the declaration name: value could run
+// script. Values containing escapes or comments are rejected as well, since
+// they can hide the patterns in unsafeCSSPatterns.
+func unsafeCSSDeclaration(name, value string) bool {
+	switch strings.ToLower(name) {
+	case "behavior", "-moz-binding":
+		return true
+	}
+	if strings.Contains(value, `\`) || strings.Contains(value, "/*") {
+		return true
+	}
+	value = strings.ToLower(strings.Join(strings.Fields(value), ""))
+	for _, p := range unsafeCSSPatterns {
+		if strings.Contains(value, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterParseStyle takes no arguments and parses the inline style declaration
+// val into a map of CSS property to value. Whitespace around properties and
+// values is trimmed, and empty or malformed declarations are skipped.
+// Semicolons inside parentheses or quotes do not end a declaration.
+func filterParseStyle(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	out := make(map[string]stick.Value)
+	for _, decl := range splitStyleDeclarations(stick.CoerceString(val)) {
+		p := strings.Index(decl, ":")
+		if p < 0 {
+			continue
+		}
+		name := strings.TrimSpace(decl[:p])
+		value := strings.TrimSpace(decl[p+1:])
+		if name == "" || value == "" {
+			continue
+		}
+		out[name] = value
+	}
+	return out
+}
+
+// splitStyleDeclarations splits the inline style s on semicolons that are
+// not inside parentheses or quotes.
+func splitStyleDeclarations(s string) []string {
+	var decls []string
+	var quote rune
+	depth, start := 0, 0
+	escaped := false
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			if depth > 0 {
+				depth--
+			}
+		case r == ';' && depth == 0:
+			decls = append(decls, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(decls, s[start:])
+}
+
+// filterNullsafe takes one optional argument, the placeholder (defaults to an
+// empty string), which is returned if val is nil. Unlike default, any other
+// value, including an empty string, is returned unchanged.
+func filterNullsafe(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if val != nil {
+		return val
+	}
+	if len(args) >= 1 {
+		return args[0]
+	}
+	return ""
+}
+
+// filterCountValues takes no arguments and returns a map of each distinct
+// element in val, coerced into a string, to the number of times it occurs.
+func filterCountValues(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	out := make(map[string]stick.Value)
+	for _, v := range values {
+		k := stick.CoerceString(v)
+		n, _ := out[k].(int)
+		out[k] = n + 1
+	}
+	return out
+}
+
+// filterDuplicates takes no arguments and returns the distinct elements of val
+// that occur more than once, in the order in which they are first repeated.
+// Elements are compared loosely, using stick.Equal.
+func filterDuplicates(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	seen := []stick.Value{}
+	out := []stick.Value{}
+	for _, v := range values {
+		if found, _ := stick.Contains(seen, v); !found {
+			seen = append(seen, v)
+			continue
+		}
+		if found, _ := stick.Contains(out, v); !found {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// randIntn returns a random integer in [0, n). It may be replaced to make
+// random filters deterministic.
+var randIntn = rand.Intn
+
+// filterSample takes one optional argument, n (defaults to 1), and returns n
+// randomly chosen elements of val, without replacement. Value n is clamped to
+// the length of val.
+func filterSample(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	n := 1
+	if len(args) >= 1 {
+		n = int(stick.CoerceNumber(args[0]))
+	}
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > len(values) {
+		n = len(values)
+	}
+	for i := 0; i < n; i++ {
+		j := i + randIntn(len(values)-i)
+		values[i], values[j] = values[j], values[i]
+	}
+	return values[:n]
+}
+
+// filterRotate takes one argument, n, and returns a new list with the
+// elements of val cyclically shifted left by n positions, so that the first
+// n elements move to the end. A negative n shifts right instead.
+func filterRotate(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	l := len(values)
+	if l == 0 || len(args) < 1 {
+		return values
+	}
+	n := int(stick.CoerceNumber(args[0])) % l
+	if n < 0 {
+		n += l
+	}
+	return append(values[n:], values[:n]...)
+}
+
+// filterKeyBy takes one argument, a field name, and returns a map of the
+// elements of val keyed by their value for that field. When several elements
+// share a key, the last one wins. Elements without the field are skipped.
+func filterKeyBy(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 1 {
+		return nil
+	}
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	out := make(map[string]stick.Value)
+	for _, v := range values {
+		k, err := stick.GetAttr(v, args[0])
+		if err != nil {
+			continue
+		}
+		out[stick.CoerceString(k)] = v
+	}
+	return out
+}
+
+// filterCanonicalJSON takes no arguments and returns val encoded as canonical
+// JSON: object keys are sorted at every level, HTML characters are not
+// escaped, and no insignificant whitespace is emitted. The same value always
+// produces the same output, making it suitable for hashing and signing. An
+// empty string is returned if val cannot be encoded.
+func filterCanonicalJSON(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(jsonValue(val)); err != nil {
+		// TODO: Report error
+		return ""
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// jsonValue recursively converts val into a value suitable for encoding/json.
+// Maps of any key type become maps with string keys, which the encoder emits
+// in sorted order, and slices and arrays become []interface{}.
+func jsonValue(val stick.Value) interface{} {
+	if sv, ok := val.(stick.SafeValue); ok {
+		return jsonValue(sv.Value())
+	}
+	if _, ok := val.(json.Marshaler); ok {
+		return val
+	}
+	switch {
+	case val == nil:
+		return nil
+	case stick.IsMap(val):
+		out := make(map[string]interface{})
+		stick.Iterate(val, func(k, v stick.Value, l stick.Loop) (bool, error) {
+			out[stick.CoerceString(k)] = jsonValue(v)
+			return false, nil
+		})
+		return out
+	case stick.IsArray(val):
+		if _, ok := val.([]byte); ok {
+			return val
+		}
+		out := []interface{}{}
+		stick.Iterate(val, func(k, v stick.Value, l stick.Loop) (bool, error) {
+			out = append(out, jsonValue(v))
+			return false, nil
+		})
+		return out
+	}
+	return val
+}
+
+// filterSigned takes one optional argument, the prefix for zero (defaults to
+// an empty string), and returns val with an explicit sign. Positive numbers
+// are prefixed with "+" and negative numbers keep their "-". The sign is
+// determined from the digits of the coerced string, so already formatted
+// numbers such as the output of number_format are supported.
+func filterSigned(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	zero := ""
+	if len(args) >= 1 {
+		zero = stick.CoerceString(args[0])
+	}
+	s := strings.TrimSpace(stick.CoerceString(val))
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		return s
+	}
+	if strings.IndexAny(s, "123456789") >= 0 {
+		return "+" + s
+	}
+	return zero + s
+}
+
+// filterClamp takes two arguments, min and max, and returns val constrained
+// to that range. Value val will be coerced into a number. If min is greater
+// than max, the bounds are swapped.
+func filterClamp(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 2 {
+		return nil
+	}
+	n := stick.CoerceNumber(val)
+	lo, hi := stick.CoerceNumber(args[0]), stick.CoerceNumber(args[1])
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	return math.Max(lo, math.Min(hi, n))
+}
+
+// filterRescale takes four arguments, the input range minimum and maximum and
+// the output range minimum and maximum, and linearly maps val from the input
+// range onto the output range. Values outside the input range are
+// extrapolated rather than clamped. If the input range has zero width, nil is
+// returned.
+func filterRescale(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 4 {
+		return nil
+	}
+	n := stick.CoerceNumber(val)
+	inMin, inMax := stick.CoerceNumber(args[0]), stick.CoerceNumber(args[1])
+	outMin, outMax := stick.CoerceNumber(args[2]), stick.CoerceNumber(args[3])
+	if inMin == inMax {
+		// TODO: Report error
+		return nil
+	}
+	return outMin + (n-inMin)*(outMax-outMin)/(inMax-inMin)
+}
+
+// filterStars takes four optional arguments: the maximum rating (defaults to
+// 5), and the full, empty and half star glyphs (defaulting to "★", "☆" and
+// "½"). The rating val is clamped to [0, max] and rounded to the nearest half
+// star, and rendered as a string of glyphs. Passing an empty half glyph
+// disables half stars, rounding to the nearest whole star instead.
+func filterStars(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	max := 5
+	full, empty, half := "★", "☆", "½"
+	if l := len(args); l >= 1 {
+		max = int(stick.CoerceNumber(args[0]))
+		if l >= 2 {
+			full = stick.CoerceString(args[1])
+			if l >= 3 {
+				empty = stick.CoerceString(args[2])
+				if l >= 4 {
+					half = stick.CoerceString(args[3])
+				}
+			}
+		}
+	}
+	if max < 0 {
+		max = 0
+	}
+	rating := math.Max(0, math.Min(float64(max), stick.CoerceNumber(val)))
+	var whole, halves int
+	if half == "" {
+		whole = int(math.Round(rating))
+	} else {
+		doubled := int(math.Round(rating * 2))
+		whole, halves = doubled/2, doubled%2
+	}
+	return strings.Repeat(full, whole) + strings.Repeat(half, halves) + strings.Repeat(empty, max-whole-halves)
+}
+
+// filterStripBOM takes no arguments and returns val with a leading byte order
+// mark removed. A UTF-8 BOM is simply stripped, while content starting with a
+// UTF-16 BOM is also decoded into UTF-8. Strings without a BOM are returned
+// unchanged.
+func filterStripBOM(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	s := stick.CoerceString(val)
+	switch {
+	case strings.HasPrefix(s, "\xEF\xBB\xBF"):
+		return s[3:]
+	case strings.HasPrefix(s, "\xFE\xFF"):
+		return decodeUTF16(s[2:], true)
+	case strings.HasPrefix(s, "\xFF\xFE"):
+		return decodeUTF16(s[2:], false)
+	}
+	return s
+}
+
+// decodeUTF16 decodes the UTF-16 encoded s into a UTF-8 string. A trailing
+// odd byte is dropped.
+func decodeUTF16(s string, bigEndian bool) string {
+	units := make([]uint16, len(s)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+		} else {
+			units[i] = uint16(s[2*i+1])<<8 | uint16(s[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// filterSqueeze takes one optional argument, a set of characters, and returns
+// val with each run of a repeated character from the set collapsed into a
+// single instance, like "tr -s". By default, whitespace is squeezed.
+func filterSqueeze(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	inSet := unicode.IsSpace
+	if len(args) >= 1 {
+		set := stick.CoerceString(args[0])
+		inSet = func(r rune) bool { return strings.ContainsRune(set, r) }
+	}
+	var out []rune
+	for _, r := range stick.CoerceString(val) {
+		if len(out) > 0 && out[len(out)-1] == r && inSet(r) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// filterReverseWords takes no arguments and returns val with the order of its
+// whitespace-separated words reversed. Words are joined by single spaces, so
+// leading, trailing and repeated whitespace is not preserved.
+func filterReverseWords(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	words := strings.Fields(stick.CoerceString(val))
+	for i, j := 0, len(words)-1; i < j; i, j = i+1, j-1 {
+		words[i], words[j] = words[j], words[i]
+	}
+	return strings.Join(words, " ")
+}
+
+// filterSwapCase takes no arguments and returns val with the case of each
+// letter inverted. Characters without case are left unchanged.
+func filterSwapCase(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsUpper(r):
+			return unicode.ToLower(r)
+		case unicode.IsLower(r):
+			return unicode.ToUpper(r)
+		}
+		return r
+	}, stick.CoerceString(val))
+}
+
+// filterCenter takes one argument, the target width, and an optional fill
+// character (defaults to a space). Value val is padded on both sides with the
+// fill character until it is width characters long, with any odd padding
+// character added on the right. Strings already at or beyond the width are
+// returned unchanged.
+func filterCenter(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	s := stick.CoerceString(val)
+	if len(args) < 1 {
+		return s
+	}
+	width := int(stick.CoerceNumber(args[0]))
+	fill := " "
+	if len(args) >= 2 {
+		if r, size := utf8.DecodeRuneInString(stick.CoerceString(args[1])); size > 0 {
+			fill = string(r)
+		}
+	}
+	padding := width - utf8.RuneCountInString(s)
+	if padding <= 0 {
+		return s
+	}
+	left := padding / 2
+	return strings.Repeat(fill, left) + s + strings.Repeat(fill, padding-left)
+}
+
+// enclosePairs maps opening delimiters to their closing counterparts.
+var enclosePairs = map[string]string{
+	"(": ")",
+	"[": "]",
+	"{": "}",
+	"<": ">",
+}
+
+// filterEnclose takes up to two arguments and returns val surrounded by
+// delimiters. With no arguments, val is enclosed in double quotes. A single
+// argument is either an opening bracket such as "(", a bracket pair such as
+// "[]", or a delimiter used on both sides such as "'" or "/*". With two
+// arguments, the first is used as the opening and the second as the closing
+// delimiter.
+func filterEnclose(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	open, close := `"`, `"`
+	if l := len(args); l >= 2 {
+		open, close = stick.CoerceString(args[0]), stick.CoerceString(args[1])
+	} else if l == 1 {
+		open = stick.CoerceString(args[0])
+		close = open
+		if c, ok := enclosePairs[open]; ok {
+			close = c
+		} else if r := []rune(open); len(r) == 2 && enclosePairs[string(r[0])] == string(r[1]) {
+			open, close = string(r[0]), string(r[1])
+		}
+	}
+	return open + stick.CoerceString(val) + close
+}
+
+// filterHTMLList takes one optional argument, the list type "ul" (default) or
+// "ol", and renders val as an HTML list. Each element is HTML escaped unless
+// it is already safe, and nested lists are rendered as nested list elements of
+// the same type. The result is marked safe for HTML.
+func filterHTMLList(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	tag := "ul"
+	if len(args) >= 1 && stick.CoerceString(args[0]) == "ol" {
+		tag = "ol"
+	}
+	if !stick.IsIterable(val) {
+		return nil
+	}
+	return stick.NewSafeValue(renderHTMLList(val, tag), "html")
+}
+
+// renderHTMLList renders the iterable val as an HTML list of type tag.
+func renderHTMLList(val stick.Value, tag string) string {
+	out := &bytes.Buffer{}
+	out.WriteString("<" + tag + ">")
+	stick.Iterate(val, func(k, v stick.Value, l stick.Loop) (bool, error) {
+		out.WriteString("<li>")
+		if stick.IsArray(v) {
+			out.WriteString(renderHTMLList(v, tag))
+		} else {
+			out.WriteString(escapeHTMLValue(v))
+		}
+		out.WriteString("</li>")
+		return false, nil
+	})
+	out.WriteString("</" + tag + ">")
+	return out.String()
+}
+
+// escapeHTMLValue returns val coerced into a string and HTML escaped, unless
+// val is already safe for HTML.
+func escapeHTMLValue(val stick.Value) string {
+	if sv, ok := val.(stick.SafeValue); ok && sv.IsSafe("html") {
+		return stick.CoerceString(sv)
+	}
+	return escape.HTML(stick.CoerceString(val))
+}
+
+// filterHTMLTable takes one optional argument, a list of column names, and
+// renders val, a list of maps, as an HTML table with a header row. Without
+// the argument, the columns are the sorted union of the keys of all rows.
+// Missing cells are rendered empty, and headers and cells are HTML escaped
+// unless already safe. The result is marked safe for HTML.
+func filterHTMLTable(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	rows, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	maps := make([]map[string]stick.Value, len(rows))
+	for i, row := range rows {
+		maps[i] = copyMap(row)
+	}
+	var columns []string
+	if len(args) >= 1 && stick.IsIterable(args[0]) {
+		stick.Iterate(args[0], func(k, v stick.Value, l stick.Loop) (bool, error) {
+			columns = append(columns, stick.CoerceString(v))
+			return false, nil
+		})
+	} else {
+		seen := make(map[string]bool)
+		for _, m := range maps {
+			for k := range m {
+				if !seen[k] {
+					seen[k] = true
+					columns = append(columns, k)
+				}
+			}
+		}
+		sort.Strings(columns)
+	}
+	out := &bytes.Buffer{}
+	out.WriteString("<table><thead><tr>")
+	for _, c := range columns {
+		out.WriteString("<th>" + escape.HTML(c) + "</th>")
+	}
+	out.WriteString("</tr></thead><tbody>")
+	for _, m := range maps {
+		out.WriteString("<tr>")
+		for _, c := range columns {
+			out.WriteString("<td>" + escapeHTMLValue(m[c]) + "</td>")
+		}
+		out.WriteString("</tr>")
+	}
+	out.WriteString("</tbody></table>")
+	return stick.NewSafeValue(out.String(), "html")
+}
+
+// filterDL takes no arguments and renders the map val as an HTML definition
+// list, with a term for each key and a description for its value. Entries are
+// emitted in sorted key order, and keys and values are HTML escaped unless
+// already safe. The result is marked safe for HTML.
+func filterDL(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if !stick.IsMap(val) {
+		return nil
+	}
+	m := copyMap(val)
+	out := &bytes.Buffer{}
+	out.WriteString("<dl>")
+	for _, k := range sortedKeys(m) {
+		out.WriteString("<dt>" + escape.HTML(k) + "</dt><dd>" + escapeHTMLValue(m[k]) + "</dd>")
+	}
+	out.WriteString("</dl>")
+	return stick.NewSafeValue(out.String(), "html")
+}
+
+// filterToJSONArray takes no arguments and returns val encoded as a JSON
+// array. Maps are encoded as an array of their values in sorted key order,
+// nil becomes an empty array, and scalars are wrapped in a single-element
+// array. An empty string is returned if val cannot be encoded.
+func filterToJSONArray(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	values := []stick.Value{}
+	switch {
+	case stick.IsMap(val):
+		m := copyMap(val)
+		for _, k := range sortedKeys(m) {
+			values = append(values, m[k])
+		}
+	case stick.IsArray(val):
+		values, _ = iterableValues(val)
+	case val != nil:
+		values = append(values, val)
+	}
+	res, err := json.Marshal(jsonValue(values))
+	if err != nil {
+		// TODO: Report error
+		return ""
+	}
+	return string(res)
+}
+
+// filterScientific takes one optional argument, the precision (defaults to
+// 6), and returns val formatted in scientific notation. Like PHP, the
+// exponent is not zero-padded, so 12345 with a precision of 2 becomes
+// "1.23e+4". Value val will be coerced into a number.
+func filterScientific(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	precision := 6
+	if len(args) >= 1 {
+		precision = int(stick.CoerceNumber(args[0]))
+	}
+	if precision < 0 {
+		precision = 0
+	}
+	s := strconv.FormatFloat(stick.CoerceNumber(val), 'e', precision, 64)
+	p := strings.LastIndexAny(s, "+-")
+	if p < 0 {
+		// Inf or NaN
+		return s
+	}
+	exp := strings.TrimLeft(s[p+1:], "0")
+	if exp == "" {
+		exp = "0"
+	}
+	return s[:p+1] + exp
+}
+
+// filterMod takes one argument, the divisor, and returns the remainder of
+// dividing val by it. Both operands are coerced into integers and, as in PHP,
+// the result takes the sign of the dividend. If the divisor is zero, nil is
+// returned.
+func filterMod(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 1 {
+		return nil
+	}
+	divisor := int(stick.CoerceNumber(args[0]))
+	if divisor == 0 {
+		// TODO: Report error
+		return nil
+	}
+	return int(stick.CoerceNumber(val)) % divisor
+}
+
+// filterPower takes one argument, the exponent, and returns val raised to
+// that power. Both operands will be coerced into numbers. If both are
+// integers and the result is exactly representable, an integer is returned.
+func filterPower(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 1 {
+		return nil
+	}
+	base, exp := stick.CoerceNumber(val), stick.CoerceNumber(args[0])
+	res := math.Pow(base, exp)
+	if base == math.Trunc(base) && exp == math.Trunc(exp) && exp >= 0 && math.Abs(res) < 1<<53 {
+		return int(res)
+	}
+	return res
+}
+
+// filterGCD returns the greatest common divisor of the integers in val, which
+// may be an iterable or a scalar, and in any arguments, so both
+// "[12, 18]|gcd" and "12|gcd(18)" are supported. Zeros are ignored, and the
+// result is 0 if every operand is zero.
+func filterGCD(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	res := 0
+	for _, n := range integerOperands(val, args) {
+		res = gcd(res, n)
+	}
+	return res
+}
+
+// filterLCM returns the least common multiple of the integers in val, which
+// may be an iterable or a scalar, and in any arguments. The result is 0 if any
+// operand is zero.
+func filterLCM(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	ops := integerOperands(val, args)
+	if len(ops) == 0 {
+		return 0
+	}
+	res := 1
+	for _, n := range ops {
+		if n == 0 {
+			return 0
+		}
+		res = res / gcd(res, n) * n
+	}
+	return res
+}
+
+// integerOperands returns the absolute integer values of val, or its elements
+// if it is iterable, followed by those of args.
+func integerOperands(val stick.Value, args []stick.Value) []int {
+	values, ok := iterableValues(val)
+	if !ok {
+		values = []stick.Value{val}
+	}
+	var ops []int
+	for _, v := range append(values, args...) {
+		n := int(stick.CoerceNumber(v))
+		if n < 0 {
+			n = -n
+		}
+		ops = append(ops, n)
+	}
+	return ops
+}
+
+// gcd returns the greatest common divisor of the non-negative a and b.
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// htmlVoidElements lists the HTML elements that have no closing tag.
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlRawTextElements lists the elements whose content is not visible text.
+var htmlRawTextElements = map[string]bool{
+	"script": true, "style": true,
+}
+
+// filterSmartTruncateHTML takes three optional arguments: the number of
+// visible characters to keep (defaults to 30), the ellipsis (defaults to "…"),
+// and whether to avoid cutting words in half (defaults to true). Value val is
+// treated as HTML; only visible text counts towards the length, so the
+// content of script and style elements is kept whole, tags are never broken
+// and any elements still open at the cut are closed after the ellipsis. A
+// negative length is treated as 0. The result is marked safe for HTML.
+func filterSmartTruncateHTML(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	length := 30
+	ellipsis := "…"
+	preserveWords := true
+	if l := len(args); l >= 1 {
+		length = int(stick.CoerceNumber(args[0]))
+		if l >= 2 {
+			ellipsis = stick.CoerceString(args[1])
+			if l >= 3 {
+				preserveWords = stick.CoerceBool(args[2])
+			}
+		}
+	}
+	if length < 0 {
+		length = 0
+	}
+	in := stick.CoerceString(val)
+	out := &bytes.Buffer{}
+	var open []string
+	remaining := length
+	z := html.NewTokenizer(strings.NewReader(in))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			// End of input, nothing was truncated.
+			return stick.NewSafeValue(in, "html")
+		case html.TextToken:
+			// Raw must be read first, Text unescapes the buffer in place.
+			raw := string(z.Raw())
+			if n := len(open); n > 0 && htmlRawTextElements[open[n-1]] {
+				out.WriteString(raw)
+				continue
+			}
+			text := []rune(string(z.Text()))
+			if len(text) <= remaining {
+				out.WriteString(raw)
+				remaining -= len(text)
+				continue
+			}
+			cut := text[:remaining]
+			if preserveWords && !unicode.IsSpace(text[remaining]) {
+				p := len(cut) - 1
+				for p >= 0 && !unicode.IsSpace(cut[p]) {
+					p--
+				}
+				if p >= 0 || out.Len() > 0 {
+					cut = cut[:p+1]
+				}
+			}
+			out.WriteString(html.EscapeString(strings.TrimRightFunc(string(cut), unicode.IsSpace)))
+			out.WriteString(ellipsis)
+			for i := len(open) - 1; i >= 0; i-- {
+				out.WriteString("</" + open[i] + ">")
+			}
+			return stick.NewSafeValue(out.String(), "html")
+		case html.StartTagToken:
+			out.Write(z.Raw())
+			name, _ := z.TagName()
+			if !htmlVoidElements[string(name)] {
+				open = append(open, string(name))
+			}
+		case html.EndTagToken:
+			out.Write(z.Raw())
+			name, _ := z.TagName()
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == string(name) {
+					open = open[:i]
+					break
+				}
+			}
+		default:
+			out.Write(z.Raw())
+		}
+	}
+}
+
+// filterTextDirection takes no arguments and returns "rtl" or "ltr" depending
+// on the first character of val with a strong bidirectional type. Strings
+// without such a character, including empty strings, are "ltr".
+func filterTextDirection(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	return textDirection(stick.CoerceString(val))
+}
+
+// textDirection returns the direction of the first strong character in s.
+func textDirection(s string) string {
+	for _, r := range s {
+		props, _ := bidi.LookupRune(r)
+		switch props.Class() {
+		case bidi.R, bidi.AL:
+			return "rtl"
+		case bidi.L:
+			return "ltr"
+		}
+	}
+	return "ltr"
+}
+
+// filterBidiIsolate takes one optional argument, the isolate mode, and returns
+// val wrapped in Unicode bidirectional isolate characters. By default, the
+// direction of val is detected and an LRI or RLI is used; with the mode
+// "auto", an FSI is used to let the renderer detect the direction instead.
+// The isolate is terminated with a PDI. Value val is HTML escaped unless
+// already safe, and the result is marked safe for HTML.
+func filterBidiIsolate(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	s := escapeHTMLValue(val)
+	isolate := "\u2066" // LRI
+	if len(args) >= 1 && stick.CoerceString(args[0]) == "auto" {
+		isolate = "\u2068" // FSI
+	} else if textDirection(stick.CoerceString(val)) == "rtl" {
+		isolate = "\u2067" // RLI
+	}
+	return stick.NewSafeValue(isolate+s+"\u2069", "html")
+}
+
+// normalizationForms maps names to Unicode normalization forms.
+var normalizationForms = map[string]norm.Form{
+	"NFC":  norm.NFC,
+	"NFD":  norm.NFD,
+	"NFKC": norm.NFKC,
+	"NFKD": norm.NFKD,
+}
+
+// filterNormalize takes one optional argument, the normalization form "NFC"
+// (default), "NFD", "NFKC" or "NFKD", and returns val in that Unicode
+// normalization form. If the form is unknown, nil is returned.
+func filterNormalize(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	name := "NFC"
+	if len(args) >= 1 {
+		name = strings.ToUpper(stick.CoerceString(args[0]))
+	}
+	form, ok := normalizationForms[name]
+	if !ok {
+		// TODO: Report error
+		return nil
+	}
+	return form.String(stick.CoerceString(val))
+}
+
+// filterStripControl takes one optional argument, a set of characters to
+// keep, and returns val with control and invisible formatting characters,
+// such as zero-width spaces, removed. Tabs, newlines and carriage returns are
+// always kept. Passing "\u200D", for example, preserves zero-width joiners
+// used in emoji sequences.
+func filterStripControl(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	keep := "\t\n\r"
+	if len(args) >= 1 {
+		keep += stick.CoerceString(args[0])
+	}
+	return strings.Map(func(r rune) rune {
+		if (unicode.IsControl(r) || unicode.Is(unicode.Cf, r)) && !strings.ContainsRune(keep, r) {
+			return -1
+		}
+		return r
+	}, stick.CoerceString(val))
+}
+
+// filterToDuration takes no arguments and returns val as a time.Duration.
+// Strings are parsed with time.ParseDuration, such as "1h30m", while numbers
+// and numeric strings are treated as seconds. If val cannot be converted, nil
+// is returned.
+func filterToDuration(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	switch v := val.(type) {
+	case time.Duration:
+		return v
+	case string:
+		if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil {
+			return d
+		}
+		secs, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil
+		}
+		return time.Duration(secs * float64(time.Second))
+	case nil, bool:
+		return nil
+	}
+	secs := stick.CoerceNumber(val)
+	if secs == 0 && stick.CoerceString(val) == "" {
+		return nil
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+// filterBusinessDays takes one argument, the end date, and an optional list
+// of holiday dates. It returns the number of weekdays from the date val up
+// to, but not including, the end date, excluding any holidays. If the end
+// date is before val, the result is negative. If either date cannot be
+// parsed, nil is returned.
+func filterBusinessDays(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 1 {
+		return nil
+	}
+	start, err := coerceTime(val)
+	if err != nil {
+		return nil
+	}
+	end, err := coerceTime(args[0])
+	if err != nil {
+		return nil
+	}
+	holidays := make(map[string]bool)
+	if len(args) >= 2 {
+		stick.Iterate(args[1], func(k, v stick.Value, l stick.Loop) (bool, error) {
+			if h, err := coerceTime(v); err == nil {
+				holidays[h.Format("2006-01-02")] = true
+			}
+			return false, nil
+		})
+	}
+	sign := 1
+	from := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	if to.Before(from) {
+		from, to, sign = to, from, -1
+	}
+	days := 0
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			continue
+		}
+		if holidays[d.Format("2006-01-02")] {
+			continue
+		}
+		days++
+	}
+	return sign * days
+}
+
+// filterISO8601 takes one optional argument, an IANA timezone name, and
+// returns the date val formatted as an RFC 3339 (ISO 8601) string. If a
+// timezone is given, the date is converted into it first; unknown timezones
+// fall back to UTC. If val cannot be parsed, nil is returned.
+func filterISO8601(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	t, err := coerceTime(val)
+	if err != nil {
+		return nil
+	}
+	if len(args) >= 1 {
+		t = t.In(coerceLocation(args[0]))
+	}
+	return t.Format(time.RFC3339)
+}
+
+// filterTimestamp takes one optional argument, the unit "s" (default) or
+// "ms", and returns the date val as the number of seconds or milliseconds
+// since the Unix epoch. If val cannot be parsed, nil is returned.
+func filterTimestamp(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	t, err := coerceTime(val)
+	if err != nil {
+		return nil
+	}
+	if len(args) >= 1 && stick.CoerceString(args[0]) == "ms" {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+	return t.Unix()
+}
+
+// filterDateBucket takes one argument, the granularity "day", "week", "month"
+// or "year", and returns the start of the period containing the date val as a
+// time.Time. For weeks, an optional second argument sets the first day of the
+// week, either as a name such as "sunday" or a number from 0 (Sunday) to 6,
+// defaulting to Monday. If val cannot be parsed or the granularity is
+// unknown, nil is returned.
+func filterDateBucket(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 1 {
+		return nil
+	}
+	t, err := coerceTime(val)
+	if err != nil {
+		return nil
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch strings.ToLower(stick.CoerceString(args[0])) {
+	case "day":
+		return day
+	case "week":
+		weekStart := time.Monday
+		if len(args) >= 2 {
+			weekStart = coerceWeekday(args[1])
+		}
+		offset := (int(day.Weekday()) - int(weekStart) + 7) % 7
+		return day.AddDate(0, 0, -offset)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case "year":
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+	// TODO: Report error
+	return nil
+}
+
+// coerceWeekday converts val, a weekday name or a number from 0 (Sunday) to
+// 6, into a time.Weekday. Unknown values are treated as Monday.
+func coerceWeekday(val stick.Value) time.Weekday {
+	s := strings.ToLower(stick.CoerceString(val))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d
+		}
+	}
+	if isNumeric(val) {
+		if n := int(stick.CoerceNumber(val)); n >= 0 && n <= 6 {
+			return time.Weekday(n)
+		}
+	}
+	return time.Monday
+}
+
+// filterListAnd takes one optional argument, the conjunction (defaults to
+// "and"), and joins the elements of val into an English list with a serial
+// comma, such as "a, b, and c". Two elements are joined by the conjunction
+// alone.
+func filterListAnd(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	conjunction := "and"
+	if len(args) >= 1 {
+		conjunction = stick.CoerceString(args[0])
+	}
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = stick.CoerceString(v)
+	}
+	switch l := len(items); l {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	case 2:
+		return items[0] + " " + conjunction + " " + items[1]
+	default:
+		return strings.Join(items[:l-1], ", ") + ", " + conjunction + " " + items[l-1]
+	}
+}
+
+// filterJSONEscape takes no arguments and returns val escaped for use inside
+// a JSON string literal, without the surrounding quotes. Unlike json_encode,
+// val is always treated as a string. The result is marked safe for JSON.
+func filterJSONEscape(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	return stick.NewSafeValue(escape.JSON(stick.CoerceString(val)), "json")
+}
+
+// filterClassList takes no arguments and returns the keys of the map val,
+// a map of class names to conditions, whose values are truthy. The class
+// names are HTML escaped and joined by spaces in sorted order. The result is
+// marked safe for HTML and HTML attributes.
+func filterClassList(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if !stick.IsMap(val) {
+		return nil
+	}
+	m := copyMap(val)
+	var classes []string
+	for _, k := range sortedKeys(m) {
+		if k != "" && stick.CoerceBool(m[k]) {
+			classes = append(classes, escape.HTML(k))
+		}
+	}
+	return stick.NewSafeValue(strings.Join(classes, " "), "html", "html_attr")
+}
+
+// filterCheckmark takes two optional arguments, the glyphs for true and false
+// (defaulting to "✓" and "✗"), and returns the glyph matching the truthiness
+// of val. Custom glyphs are HTML escaped unless already safe, so markup such
+// as icon elements must be passed through raw. The result is marked safe for
+// HTML.
+func filterCheckmark(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	var yes, no stick.Value = "✓", "✗"
+	if l := len(args); l >= 1 {
+		yes = args[0]
+		if l >= 2 {
+			no = args[1]
+		}
+	}
+	if stick.CoerceBool(val) {
+		return stick.NewSafeValue(escapeHTMLValue(yes), "html")
+	}
+	return stick.NewSafeValue(escapeHTMLValue(no), "html")
+}
+
+// filterReadingTime takes one optional argument, the reading speed in words
+// per minute (defaults to 200), and returns the estimated number of minutes
+// needed to read val, rounded up. HTML tags are stripped before counting
+// words.
+func filterReadingTime(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	wpm := 200.0
+	if len(args) >= 1 {
+		wpm = stick.CoerceNumber(args[0])
+	}
+	if wpm <= 0 {
+		return nil
+	}
+	text := stick.CoerceString(filterStripTags(ctx, val))
+	words := len(strings.Fields(html.UnescapeString(text)))
+	return int(math.Ceil(float64(words) / wpm))
+}
+
+// filterHashtags takes one optional argument, the tag prefix (defaults to
+// "#"), and returns a list of the tags found in val, without their prefix.
+// A tag must follow whitespace, punctuation or the start of the string and
+// consists of letters, digits and underscores, so "mail@example.com" does
+// not contain a tag prefixed with "@".
+func filterHashtags(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	prefix := "#"
+	if len(args) >= 1 {
+		prefix = stick.CoerceString(args[0])
+	}
+	return extractTags(stick.CoerceString(val), prefix)
+}
+
+// filterMentions takes one optional argument, the mention prefix (defaults
+// to "@"), and returns a list of the mentions found in val, without their
+// prefix.
+func filterMentions(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	prefix := "@"
+	if len(args) >= 1 {
+		prefix = stick.CoerceString(args[0])
+	}
+	return extractTags(stick.CoerceString(val), prefix)
+}
+
+// extractTags returns the words in s that directly follow prefix.
+func extractTags(s string, prefix string) []stick.Value {
+	res := []stick.Value{}
+	if prefix == "" {
+		return res
+	}
+	isTagRune := func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	for i := 0; i < len(s); {
+		j := strings.Index(s[i:], prefix)
+		if j < 0 {
+			break
+		}
+		start := i + j
+		i = start + len(prefix)
+		if before, _ := utf8.DecodeLastRuneInString(s[:start]); start > 0 && isTagRune(before) {
+			continue
+		}
+		end := i
+		for end < len(s) {
+			r, size := utf8.DecodeRuneInString(s[end:])
+			if !isTagRune(r) {
+				break
+			}
+			end += size
+		}
+		if end > i {
+			res = append(res, s[i:end])
+			i = end
+		}
+	}
+	return res
+}
+
+// filterAnonymizeIP takes no arguments and returns the IP address val with
+// its host part zeroed: the last octet of an IPv4 address, or the last 80 bits
+// of an IPv6 address. Value val is returned unchanged if it is not an IP
+// address.
+func filterAnonymizeIP(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	ip := net.ParseIP(stick.CoerceString(val))
+	if ip == nil {
+		return val
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// filterCoordinate takes one optional argument, the axis "lat" (the default)
+// or "lon", and returns the decimal degrees val in degrees-minutes-seconds
+// notation, such as 40°42'46"N. Negative values are given the southern or
+// western hemisphere. Value nil is returned for an unknown axis.
+func filterCoordinate(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	hemispheres := [2]string{"N", "S"}
+	if len(args) >= 1 {
+		switch strings.ToLower(stick.CoerceString(args[0])) {
+		case "lat":
+		case "lon":
+			hemispheres = [2]string{"E", "W"}
+		default:
+			// TODO: Report error
+			return nil
+		}
+	}
+	deg := stick.CoerceNumber(val)
+	hemisphere := hemispheres[0]
+	if deg < 0 {
+		hemisphere = hemispheres[1]
+		deg = -deg
+	}
+	secs := int(math.Round(deg * 3600))
+	return fmt.Sprintf("%d°%d'%d\"%s", secs/3600, secs/60%60, secs%60, hemisphere)
+}
+
+// filterPctChange takes up to three arguments, the old value, the precision
+// (defaults to 1) and whether to show an explicit sign (defaults to false),
+// and returns the percent change from the old value to val, such as "25.0%".
+// The change is relative to the magnitude of the old value, so a rise from a
+// negative value is still an increase. Value nil is returned if the old value is zero.
+func filterPctChange(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	if len(args) < 1 {
+		// TODO: Report error
+		return nil
+	}
+	old := stick.CoerceNumber(args[0])
+	if old == 0 {
+		// TODO: Report error
+		return nil
+	}
+	precision := 1
+	sign := false
+	if l := len(args); l >= 2 {
+		precision = int(stick.CoerceNumber(args[1]))
+		if precision < 0 {
+			precision = 0
+		}
+		if l >= 3 {
+			sign = stick.CoerceBool(args[2])
+		}
+	}
+	change := (stick.CoerceNumber(val) - old) / math.Abs(old) * 100
+	s := strconv.FormatFloat(change, 'f', precision, 64)
+	if strings.IndexAny(s, "123456789") < 0 {
+		// Avoid a negative zero.
+		s = strings.TrimPrefix(s, "-")
+	}
+	if sign {
+		s = stick.CoerceString(filterSigned(ctx, s))
+	}
+	return s + "%"
+}
+
+// filterRank takes one optional argument, a list of medals for the top
+// positions (defaults to 🥇, 🥈 and 🥉), and returns the medal for the
+// position val, or its ordinal, such as "4th", if no medal is available.
+func filterRank(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	medals := []stick.Value{"🥇", "🥈", "🥉"}
+	if len(args) >= 1 {
+		var ok bool
+		if medals, ok = iterableValues(args[0]); !ok {
+			// TODO: Report error
+			return nil
+		}
+	}
+	n := int(stick.CoerceNumber(val))
+	if n >= 1 && n <= len(medals) {
+		return medals[n-1]
+	}
+	return ordinal(n)
+}
+
+// ordinal returns n followed by its English ordinal suffix, such as "1st",
+// "12th" or "23rd".
+func ordinal(n int) string {
+	abs := n % 100
+	if abs < 0 {
+		abs = -abs
+	}
+	suffix := "th"
+	if abs < 11 || abs > 13 {
+		switch abs % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// filterBreakLongWords takes up to two arguments, the break interval
+// (defaults to 20) and the break strategy "zwsp" (the default) or "wbr", and
+// returns val with a break opportunity inserted every interval characters
+// within words longer than the interval, so long tokens such as URLs can
+// wrap. With "zwsp" a zero-width space is inserted. With "wbr" val is
+// HTML-escaped and a <wbr> element is inserted, and the result is marked safe
+// for HTML. Value nil is returned for an unknown strategy.
+func filterBreakLongWords(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	interval := 20
+	strategy := "zwsp"
+	if l := len(args); l >= 1 {
+		interval = int(stick.CoerceNumber(args[0]))
+		if l >= 2 {
+			strategy = stick.CoerceString(args[1])
+		}
+	}
+	var brk string
+	esc := func(s string) string { return s }
+	switch strategy {
+	case "zwsp":
+		brk = "\u200B"
+	case "wbr":
+		brk = "<wbr>"
+		esc = html.EscapeString
+	default:
+		// TODO: Report error
+		return nil
+	}
+	if interval <= 0 {
+		// TODO: Report error
+		return nil
+	}
+	out := &bytes.Buffer{}
+	run := 0
+	for _, r := range stick.CoerceString(val) {
+		if unicode.IsSpace(r) {
+			run = 0
+		} else {
+			if run == interval {
+				out.WriteString(brk)
+				run = 0
+			}
+			run++
+		}
+		out.WriteString(esc(string(r)))
+	}
+	if strategy == "wbr" {
+		return stick.NewSafeValue(out.String(), "html")
+	}
+	return out.String()
+}
+
+// filterNatSort takes one optional argument, "desc" for descending order,
+// and returns the elements of val sorted in natural order, where runs of
+// digits are compared by their numeric value, so "file2" sorts before
+// "file10". Maps are sorted by value.
+func filterNatSort(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	values, ok := iterableValues(val)
+	if !ok {
+		return nil
+	}
+	desc := len(args) >= 1 && strings.ToLower(stick.CoerceString(args[0])) == "desc"
+	sort.SliceStable(values, func(i, j int) bool {
+		a, b := stick.CoerceString(values[i]), stick.CoerceString(values[j])
+		if desc {
+			a, b = b, a
+		}
+		return naturalCompare(a, b) < 0
+	})
+	return values
+}
+
+// naturalCompare returns -1, 0 or 1 depending on whether a sorts before,
+// together with, or after b in natural order. Runs of digits are compared by
+// their numeric value, everything else character by character. Strings that
+// are naturally equal, such as "a01" and "a1", are ordered lexically.
+func naturalCompare(a, b string) int {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	digits := func(s string, i int) (string, int) {
+		j := i
+		for j < len(s) && isDigit(s[j]) {
+			j++
+		}
+		return strings.TrimLeft(s[i:j], "0"), j
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			var na, nb string
+			na, i = digits(a, i)
+			nb, j = digits(b, j)
+			if len(na) != len(nb) {
+				if len(na) < len(nb) {
+					return -1
+				}
+				return 1
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			continue
+		}
+		ra, sa := utf8.DecodeRuneInString(a[i:])
+		rb, sb := utf8.DecodeRuneInString(b[j:])
+		if ra != rb {
+			if ra < rb {
+				return -1
+			}
+			return 1
+		}
+		i += sa
+		j += sb
+	}
+	switch {
+	case len(a)-i < len(b)-j:
+		return -1
+	case len(a)-i > len(b)-j:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// filterStringToColor takes one optional argument, the palette "normal"
+// (the default), "pastel" or "dark", and returns a hex color such as
+// "#3fa1c4" derived from a hash of val. The same value always yields the same
+// color. The hue is taken from the hash, while the palette sets the
+// saturation and lightness. Value nil is returned for an unknown palette.
+func filterStringToColor(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	saturation, lightness := 0.65, 0.5
+	if len(args) >= 1 {
+		switch stick.CoerceString(args[0]) {
+		case "normal":
+		case "pastel":
+			saturation, lightness = 0.7, 0.8
+		case "dark":
+			saturation, lightness = 0.6, 0.3
+		default:
+			// TODO: Report error
+			return nil
+		}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(stick.CoerceString(val)))
+	hue := float64(h.Sum32() % 360)
+
+	// Convert from HSL to RGB.
+	c := (1 - math.Abs(2*lightness-1)) * saturation
+	x := c * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g = c, x
+	case hue < 120:
+		r, g = x, c
+	case hue < 180:
+		g, b = c, x
+	case hue < 240:
+		g, b = x, c
+	case hue < 300:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := lightness - c/2
+	channel := func(v float64) int { return int(math.Round((v + m) * 255)) }
+	return fmt.Sprintf("#%02x%02x%02x", channel(r), channel(g), channel(b))
+}
+
+// morseCodes maps letters and digits to their Morse code.
+var morseCodes = map[rune]string{
+	'A': ".-", 'B': "-...", 'C': "-.-.", 'D': "-..", 'E': ".", 'F': "..-.",
+	'G': "--.", 'H': "....", 'I': "..", 'J': ".---", 'K': "-.-", 'L': ".-..",
+	'M': "--", 'N': "-.", 'O': "---", 'P': ".--.", 'Q': "--.-", 'R': ".-.",
+	'S': "...", 'T': "-", 'U': "..-", 'V': "...-", 'W': ".--", 'X': "-..-",
+	'Y': "-.--", 'Z': "--..",
+	'0': "-----", '1': ".----", '2': "..---", '3': "...--", '4': "....-",
+	'5': ".....", '6': "-....", '7': "--...", '8': "---..", '9': "----.",
+}
+
+// filterMorse takes up to three arguments, the dot (defaults to "."), the
+// dash (defaults to "-") and the letter separator (defaults to " "), and
+// returns val encoded as Morse code. Words are separated by a "/" surrounded
+// by the letter separator. Letters are case-insensitive, and characters other
+// than letters, digits and whitespace are skipped.
+func filterMorse(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	dot, dash, sep := ".", "-", " "
+	if l := len(args); l >= 1 {
+		dot = stick.CoerceString(args[0])
+		if l >= 2 {
+			dash = stick.CoerceString(args[1])
+			if l >= 3 {
+				sep = stick.CoerceString(args[2])
+			}
+		}
+	}
+	replacer := strings.NewReplacer(".", dot, "-", dash)
+	words := []string{}
+	for _, word := range strings.Fields(stick.CoerceString(val)) {
+		letters := []string{}
+		for _, r := range strings.ToUpper(word) {
+			if code, ok := morseCodes[r]; ok {
+				letters = append(letters, replacer.Replace(code))
+			}
+		}
+		if len(letters) > 0 {
+			words = append(words, strings.Join(letters, sep))
+		}
+	}
+	return strings.Join(words, sep+"/"+sep)
+}
+
+// filterRadix takes up to four arguments, the base from 2 to 36 (defaults to
+// 16), the group size (defaults to 0, no grouping), a prefix such as "0x"
+// (defaults to an empty string) and the group separator (defaults to " "),
+// and returns the integer val written in that base. When grouping, the digits
+// are split into groups from the right and the first group is padded with
+// zeros, so 165 in base 2 grouped by 4 is "1010 0101". Value nil is returned
+// for an invalid base.
+func filterRadix(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	base, group := 16, 0
+	prefix, sep := "", " "
+	if l := len(args); l >= 1 {
+		base = int(stick.CoerceNumber(args[0]))
+		if l >= 2 {
+			group = int(stick.CoerceNumber(args[1]))
+			if l >= 3 {
+				prefix = stick.CoerceString(args[2])
+				if l >= 4 {
+					sep = stick.CoerceString(args[3])
+				}
+			}
+		}
+	}
+	if base < 2 || base > 36 {
+		// TODO: Report error
+		return nil
+	}
+	n := int64(stick.CoerceNumber(val))
+	sign := ""
+	if n < 0 {
+		sign = "-"
+	}
+	digits := strings.TrimPrefix(strconv.FormatInt(n, base), "-")
+	if group > 0 {
+		if r := len(digits) % group; r > 0 {
+			digits = strings.Repeat("0", group-r) + digits
+		}
+		groups := make([]string, 0, len(digits)/group)
+		for i := 0; i < len(digits); i += group {
+			groups = append(groups, digits[i:i+group])
+		}
+		digits = strings.Join(groups, sep)
+	}
+	return sign + prefix + digits
+}
+
+// sentenceCaseAbbreviations lists common abbreviations whose trailing period
+// does not end a sentence.
+var sentenceCaseAbbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "st": true,
+	"jr": true, "sr": true, "vs": true, "etc": true, "approx": true,
+}
+
+// filterSentenceCase takes one optional argument, the locale, and returns
+// val lowercased with the first letter of each sentence in title case. A
+// sentence ends with ".", "!" or "?" followed by whitespace. Periods after
+// common abbreviations such as "Dr." and dotted abbreviations such as "e.g."
+// are not treated as the end of a sentence.
+func filterSentenceCase(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	tag := caseLanguage(ctx, args...)
+	title := cases.Title(tag)
+	runes := []rune(cases.Lower(tag).String(stick.CoerceString(val)))
+	out := &bytes.Buffer{}
+	capitalize, ended := true, false
+	for i, r := range runes {
+		switch {
+		case capitalize && unicode.IsLetter(r):
+			out.WriteString(title.String(string(r)))
+			capitalize = false
+			continue
+		case r == '!' || r == '?':
+			ended = true
+		case r == '.':
+			ended = !isAbbreviation(runes[:i])
+		case unicode.IsSpace(r):
+			if ended {
+				capitalize, ended = true, false
+			}
+		case !strings.ContainsRune(`"')]`, r):
+			ended = false
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// isAbbreviation returns true if the word at the end of runes, which is
+// followed by a period, looks like an abbreviation.
+func isAbbreviation(runes []rune) bool {
+	start := len(runes)
+	for start > 0 && (unicode.IsLetter(runes[start-1]) || runes[start-1] == '.') {
+		start--
+	}
+	word := string(runes[start:])
+	return strings.Contains(word, ".") || sentenceCaseAbbreviations[word]
+}
+
+// filterInitials takes one optional argument, the maximum number of
+// initials (defaults to 0, no limit), and returns the uppercased first
+// letter of each part of the name val, so "John Ronald Tolkien" becomes
+// "JRT". Hyphenated parts each contribute an initial. When limited, the
+// first initials are kept.
+func filterInitials(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	max := 0
+	if len(args) >= 1 {
+		max = int(stick.CoerceNumber(args[0]))
+	}
+	parts := strings.FieldsFunc(stick.CoerceString(val), func(r rune) bool {
+		return r == '-' || unicode.IsSpace(r)
+	})
+	res := []rune{}
+	for _, part := range parts {
+		if max > 0 && len(res) >= max {
+			break
+		}
+		if i := strings.IndexFunc(part, unicode.IsLetter); i >= 0 {
+			r, _ := utf8.DecodeRuneInString(part[i:])
+			res = append(res, unicode.ToUpper(r))
+		}
+	}
+	return string(res)
+}
+
+// filterRot13 takes one optional argument, the shift (defaults to 13), and
+// returns val with each ASCII letter shifted that many places through the
+// alphabet, like PHP's str_rot13. Other characters are left unchanged. A
+// negative shift rotates backwards, so a shift of -n undoes a shift of n.
+func filterRot13(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	shift := 13
+	if len(args) >= 1 {
+		shift = int(stick.CoerceNumber(args[0]))
+	}
+	shift = (shift%26 + 26) % 26
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+rune(shift))%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+rune(shift))%26
+		}
+		return r
+	}, stick.CoerceString(val))
+}
+
+// boolStrings maps the styles accepted by bool_string to their true and false
+// strings.
+var boolStrings = map[string][2]string{
+	"true": {"true", "false"},
+	"yes":  {"yes", "no"},
+	"on":   {"on", "off"},
+	"1":    {"1", "0"},
+}
+
+// filterBoolString takes one optional argument, the style "true" (the
+// default), "yes", "on" or "1", and returns the truthiness of val as a
+// lowercase string in that style, such as "true" or "false". Value nil is
+// returned for an unknown style.
+func filterBoolString(ctx stick.Context, val stick.Value, args ...stick.Value) stick.Value {
+	style := "true"
+	if len(args) >= 1 {
+		style = strings.ToLower(stick.CoerceString(args[0]))
+	}
+	strs, ok := boolStrings[style]
+	if !ok {
+		// TODO: Report error
+		return nil
+	}
+	if stick.CoerceBool(val) {
+		return strs[0]
+	}
+	return strs[1]
+}
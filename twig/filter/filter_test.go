@@ -1,6 +1,10 @@
 package filter
 
 import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
 	"testing"
 
 	"github.com/tyler-sommer/stick"
@@ -25,6 +29,22 @@ func TestFilters(t *testing.T) {
 		}
 	}
 
+	isEven := func(v stick.Value) bool { return int(stick.CoerceNumber(v))%2 == 0 }
+	menu := []stick.Value{
+		map[string]stick.Value{"id": 1, "parent_id": nil, "name": "home"},
+		map[string]stick.Value{"id": 2, "parent_id": 1, "name": "about"},
+		map[string]stick.Value{"id": 3, "parent_id": 0, "name": "blog"},
+		map[string]stick.Value{"id": 4, "parent_id": 3, "name": "news"},
+		map[string]stick.Value{"id": 5, "parent_id": 1, "name": "team"},
+	}
+	orphans := []stick.Value{
+		map[string]stick.Value{"id": 1, "parent_id": 0, "name": "home"},
+		map[string]stick.Value{"id": 2, "parent_id": 9, "name": "lost"},
+	}
+
+	defer func(fn func(int) int) { randIntn = fn }(randIntn)
+	randIntn = rand.New(rand.NewSource(42)).Intn
+
 	tz, err := time.LoadLocation("Australia/Perth")
 	if nil != err {
 		t.Error(err)
@@ -40,6 +60,9 @@ func TestFilters(t *testing.T) {
 		{"default nil", func() stick.Value { return filterDefault(nil, nil, "person") }, "person"},
 		{"default empty string", func() stick.Value { return filterDefault(nil, "", "person") }, "person"},
 		{"default not empty", func() stick.Value { return filterDefault(nil, "user", "person") }, "user"},
+		{"default whitespace", func() stick.Value { return filterDefault(nil, "   ", "person") }, "   "},
+		{"default whitespace trimmed", func() stick.Value { return filterDefault(nil, "   ", "person", true) }, "person"},
+		{"default whitespace not trimmed", func() stick.Value { return filterDefault(nil, "   ", "person", false) }, "   "},
 		{"abs positive", func() stick.Value { return filterAbs(nil, 5.1) }, 5.1},
 		{"abs negative", func() stick.Value { return filterAbs(nil, -42) }, 42.0 /* note: coerced to float */},
 		{"abs invalid", func() stick.Value { return filterAbs(nil, "invalid") }, 0.0},
@@ -47,10 +70,20 @@ func TestFilters(t *testing.T) {
 		{"len nil", func() stick.Value { return filterLength(nil, nil) }, 0},
 		{"len slice", func() stick.Value { return filterLength(nil, []string{"h", "e"}) }, 2},
 		{"capitalize", func() stick.Value { return filterCapitalize(nil, "word") }, "Word"},
+		{"capitalize empty", func() stick.Value { return filterCapitalize(nil, "") }, ""},
+		{"capitalize multibyte", func() stick.Value { return filterCapitalize(nil, "élan") }, "Élan"},
+		{"capitalize default locale", func() stick.Value { return filterCapitalize(nil, "istanbul") }, "Istanbul"},
+		{"capitalize turkish", func() stick.Value { return filterCapitalize(nil, "istanbul", "tr") }, "İstanbul"},
+		{"capitalize sharp s", func() stick.Value { return filterCapitalize(nil, "ßa") }, "Ssa"},
+		{"capitalize digraph", func() stick.Value { return filterCapitalize(nil, "ǆungla") }, "ǅungla"},
 		{"lower", func() stick.Value { return filterLower(nil, "HELLO, WORLD!") }, "hello, world!"},
+		{"lower dotless i", func() stick.Value { return filterLower(nil, "ISPARTA") }, "isparta"},
+		{"lower turkish dotless i", func() stick.Value { return filterLower(nil, "ISPARTA", "tr") }, "ısparta"},
 		{"title", func() stick.Value { return filterTitle(nil, "hello, world!") }, "Hello, World!"},
+		{"title turkish", func() stick.Value { return filterTitle(nil, "izmir ili", "tr") }, "İzmir İli"},
 		{"trim", func() stick.Value { return filterTrim(nil, " Hello   ") }, "Hello"},
 		{"upper", func() stick.Value { return filterUpper(nil, "hello, world!") }, "HELLO, WORLD!"},
+		{"upper turkish", func() stick.Value { return filterUpper(nil, "istanbul", "tr") }, "İSTANBUL"},
 		{"batch underfull with fill", newBatchFunc([]int{1, 2, 3, 4, 5, 6, 7, 8}, 3, "No Item"), "1.2.3..4.5.6..7.8.No Item.."},
 		{"batch underfull without fill", newBatchFunc([]int{1, 2, 3, 4, 5}, 3), "1.2.3..4.5.."},
 		{"batch full", newBatchFunc([]int{1, 2, 3, 4}, 2), "1.2..3.4.."},
@@ -62,8 +95,650 @@ func TestFilters(t *testing.T) {
 		{"date r", func() stick.Value { return filterDate(nil, testDate, "r") }, "Sat, 31 May 1980 22:01:00 +0800"},
 		{"date test", func() stick.Value { return filterDate(nil, testDate2, "d D j l F m M n Y y a A g G h H i s O P T")}, "03 Sat 3 Saturday February 02 Feb 2 2018 18 am AM 2 02 02 02 01 44 +0800 +08:00 AWST"},
 		{"date u", func() stick.Value { return filterDate(nil, testDate2, "s.u") }, "44.123456"},
+		{"date no leading whitespace", func() stick.Value { return filterDate(nil, "2020-03-04", "yyyy") }, "2020"},
+		{"date default layout", func() stick.Value { return filterDate(nil, "2020-03-04") }, "2020-03-04"},
+		{"dateTime no leading whitespace", func() stick.Value { return filterDateTime(nil, "2020-03-04 17:05:09", "dd.MM.yyyy HH:mm") }, "04.03.2020 17:05"},
+		{"time no leading whitespace", func() stick.Value { return filterTime(nil, "17:05:09", "HH:mm") }, "17:05"},
+		{"date quoted literal", func() stick.Value { return filterDate(nil, "2020-03-04", "'Year' yyyy, 'day' d") }, "Year 2020, day 4"},
+		{"time literal resembling layout", func() stick.Value { return filterTime(nil, "17:05:09", "HH'h'mm 'Mon 2'") }, "17h05 Mon 2"},
+		{"date full names", func() stick.Value { return filterDate(nil, "2020-03-04", "EEEE, MMMM d") }, "Wednesday, March 4"},
+		{"date abbreviated names", func() stick.Value { return filterDate(nil, "2020-03-04", "EEE d MMM yyyy") }, "Wed 4 Mar 2020"},
+		{"time meridiem", func() stick.Value { return filterTime(nil, "15:04:05", "hh:mm A") }, "03:04 PM"},
+		{"time meridiem lowercase", func() stick.Value { return filterTime(nil, "09:30:00", "h:mm a") }, "9:30 am"},
+		{"dateTime meridiem", func() stick.Value { return filterDateTime(nil, "2020-03-04 00:15:00", "MMM d, h:mm A") }, "Mar 4, 12:15 AM"},
+		{"dateTime timezone", func() stick.Value { return filterDateTime(nil, "2020-03-04 12:00:00", "HH:mm Z", "Europe/Prague") }, "13:00 +0100"},
+		{"dateTime timezone summer", func() stick.Value { return filterDateTime(nil, "2020-07-04 12:00:00", "HH:mm", "Europe/Prague") }, "14:00"},
+		{"dateTime invalid timezone", func() stick.Value { return filterDateTime(nil, "2020-03-04 12:00:00", "HH:mm Z", "Mars/Olympus") }, "12:00 +0000"},
+		{"date timezone", func() stick.Value { return filterDate(nil, "2020-03-04", "yyyy-MM-dd HH:mm", "America/New_York") }, "2020-03-04 00:00"},
+		{"date timezone converts offsets", func() stick.Value {
+			return filterDate(nil, "2020-03-04T00:00:00Z", "yyyy-MM-dd HH:mm", "America/New_York")
+		}, "2020-03-03 19:00"},
+		{"date timezone converts timestamps", func() stick.Value { return filterDate(nil, 1583280000, "yyyy-MM-dd HH:mm", "America/New_York") }, "2020-03-03 19:00"},
+		{"date time.Time", func() stick.Value { return filterDate(nil, testDate, "yyyy-MM-dd HH:mm Z") }, "1980-05-31 22:01 +0800"},
+		{"date unix timestamp", func() stick.Value { return filterDate(nil, 1583280000) }, "2020-03-04"},
+		{"date string", func() stick.Value { return filterDate(nil, "2020-03-04", "d.M.yyyy") }, "4.3.2020"},
+		{"dateTime time.Time", func() stick.Value { return filterDateTime(nil, testDate2) }, "2018-02-03 02:01:44"},
+		{"dateTime unix timestamp", func() stick.Value { return filterDateTime(nil, int64(1583325045), "yyyy-MM-dd HH:mm:ss.SSS") }, "2020-03-04 12:30:45.000"},
+		{"dateTime float timestamp", func() stick.Value { return filterDateTime(nil, 1583325045.25, "HH:mm:ss.SSS") }, "12:30:45.250"},
+		{"time time.Time", func() stick.Value { return filterTime(nil, testDate2, "HH:mm") }, "02:01"},
+		{"time unix timestamp", func() stick.Value { return filterTime(nil, 45296) }, "12:34:56"},
+		{"time numeric string", func() stick.Value { return filterTime(nil, "45296") }, nil},
+		{"dateTime rfc3339", func() stick.Value { return filterDateTime(nil, "2020-03-04T12:30:45Z") }, "2020-03-04 12:30:45"},
+		{"dateTime rfc3339 offset", func() stick.Value { return filterDateTime(nil, "2020-03-04T12:30:45+02:00", "HH:mm Z") }, "12:30 +0200"},
+		{"dateTime rfc3339 fraction", func() stick.Value { return filterDateTime(nil, "2020-03-04T12:30:45.678Z", "ss.SSS") }, "45.678"},
+		{"dateTime iso without zone", func() stick.Value { return filterDateTime(nil, "2020-03-04T12:30:45") }, "2020-03-04 12:30:45"},
+		{"dateTime mariadb fraction", func() stick.Value { return filterDateTime(nil, "2020-03-04 12:30:45.5", "HH:mm:ss.SSS") }, "12:30:45.500"},
+		{"dateTime date only", func() stick.Value { return filterDateTime(nil, "2020-03-04", "yyyy-MM-dd HH:mm") }, "2020-03-04 00:00"},
+		{"date from datetime", func() stick.Value { return filterDate(nil, "2020-03-04 12:30:45") }, "2020-03-04"},
+		{"date from rfc3339", func() stick.Value { return filterDate(nil, "2020-03-04T23:30:00-05:00", "yyyy-MM-dd", "UTC") }, "2020-03-05"},
+		{"time from rfc3339", func() stick.Value { return filterTime(nil, "2020-03-04T12:30:45Z", "HH:mm") }, "12:30"},
+		{"time only", func() stick.Value { return filterTime(nil, "12:30:45.25", "HH:mm:ss.SSS") }, "12:30:45.250"},
+		{"date unparsable", func() stick.Value { return filterDate(nil, "04/03/2020") }, nil},
+		{"json_encode nil", func() stick.Value { return filterJSONEncode(nil, nil) }, "null"},
+		{"json_encode scalars", func() stick.Value { return filterJSONEncode(nil, []stick.Value{1, 2.5, "a", true, false}) }, `[1,2.5,"a",true,false]`},
+		{"json_encode nested", func() stick.Value {
+			return filterJSONEncode(nil, map[string]stick.Value{"b": []stick.Value{1, map[string]stick.Value{"c": nil}}, "a": "x"})
+		}, `{"a":"x","b":[1,{"c":null}]}`},
+		{"json_encode pretty", func() stick.Value {
+			return filterJSONEncode(nil, map[string]stick.Value{"a": []stick.Value{1, 2}}, 4)
+		}, "{\n    \"a\": [\n        1,\n        2\n    ]\n}"},
+		{"json_encode error", func() stick.Value { return filterJSONEncode(nil, func() {}) }, ""},
+		{"keys map", func() stick.Value {
+			return stickSliceToString(filterKeys(nil, map[string]stick.Value{"zeta": 1, "alpha": 2, "mu": 3, "beta": 4}))
+		}, "alpha.beta.mu.zeta"},
+		{"keys slice", func() stick.Value { return stickSliceToString(filterKeys(nil, []string{"a", "b", "c"})) }, "0.1.2"},
+		{"keys scalar", func() stick.Value { return filterLength(nil, filterKeys(nil, "abc")) }, 0},
+		{"last array", func() stick.Value { return filterLast(nil, []string{"1", "2", "3", "4"}) }, "4"},
+		{"last empty array", func() stick.Value { return filterLast(nil, []string{}) }, nil},
+		{"last string", func() stick.Value { return filterLast(nil, "1234") }, "4"},
+		{"last multibyte string", func() stick.Value { return filterLast(nil, "héllo") }, "o"},
+		{"last multibyte rune", func() stick.Value { return filterLast(nil, "café") }, "é"},
+		{"last empty string", func() stick.Value { return filterLast(nil, "") }, nil},
+		{"last map", func() stick.Value { return filterLast(nil, map[string]stick.Value{"a": 1}) }, nil},
+		{"last map with several keys", func() stick.Value { return filterLast(nil, map[string]int{"a": 1, "b": 2, "c": 3}) }, nil},
+		{"reverse string", func() stick.Value { return filterReverse(nil, "décor") }, "rocéd"},
+		{"reverse number", func() stick.Value { return filterReverse(nil, 1234) }, "4321"},
+		{"reverse slice", func() stick.Value { return stickSliceToString(filterReverse(nil, []int{1, 2, 3, 4, 5})) }, "5.4.3.2.1"},
+		{"reverse does not mutate", func() stick.Value {
+			in := []stick.Value{1, 2, 3}
+			filterReverse(nil, in)
+			return stickSliceToString(in)
+		}, "1.2.3"},
+		{"sort numeric strings", func() stick.Value { return stickSliceToString(filterSort(nil, []string{"10", "2", "1"})) }, "1.2.10"},
+		{"sort mixed", func() stick.Value { return stickSliceToString(filterSort(nil, []stick.Value{"b", 3, "a", 1.5})) }, "1.5.3.a.b"},
+		{"sort mixed total order", func() stick.Value {
+			return stickSliceToString(filterSort(nil, []string{"9a", "10", "9"})) + " " + stickSliceToString(filterSort(nil, []string{"9", "10", "9a"}))
+		}, "9.10.9a 9.10.9a"},
+		{"sort fractional comparator", func() stick.Value {
+			diff := stick.Func(func(ctx stick.Context, args ...stick.Value) stick.Value {
+				return stick.CoerceNumber(args[0]) - stick.CoerceNumber(args[1])
+			})
+			return stickSliceToString(filterSort(nil, []float64{0.3, 0.1, 0.2}, diff))
+		}, "0.1.0.2.0.3"},
+		{"sort map drops keys", func() stick.Value {
+			_, ok := filterSort(nil, map[string]int{"x": 2, "y": 1}).([]stick.Value)
+			return ok
+		}, true},
+		{"sort desc", func() stick.Value { return stickSliceToString(filterSort(nil, []int{2, 10, 1}, "desc")) }, "10.2.1"},
+		{"sort comparator", func() stick.Value {
+			byLength := func(a, b stick.Value) int { return len(stick.CoerceString(a)) - len(stick.CoerceString(b)) }
+			return stickSliceToString(filterSort(nil, []string{"ccc", "a", "bb"}, byLength))
+		}, "a.bb.ccc"},
+		{"sort map", func() stick.Value { return stickSliceToString(filterSort(nil, map[string]stick.Value{"x": 3, "y": 1, "z": 2})) }, "1.2.3"},
+		{"split", func() stick.Value { return stickSliceToString(filterSplit(nil, "one,two,three", ",")) }, "one.two.three"},
+		{"split limit", func() stick.Value { return stickSliceToString(filterSplit(nil, "one,two,three", ",", 2)) }, "one.two,three"},
+		{"split zero limit", func() stick.Value { return stickSliceToString(filterSplit(nil, "a,b,c", ",", 0)) }, "a.b.c"},
+		{"split empty delimiter", func() stick.Value { return stickSliceToString(filterSplit(nil, "abc", "")) }, "a.b.c"},
+		{"split empty delimiter chunks", func() stick.Value { return stickSliceToString(filterSplit(nil, "aabbcçd", "", 2)) }, "aa.bb.cç.d"},
+		{"replace map", func() stick.Value {
+			return filterReplace(nil, "I like %this% and %that%.", map[string]stick.Value{"%this%": "foo", "%that%": "bar"})
+		}, "I like foo and bar."},
+		{"replace name", func() stick.Value { return filterReplace(nil, "Hello %name%", map[string]stick.Value{"%name%": "Fabien"}) }, "Hello Fabien"},
+		{"replace longest match", func() stick.Value { return filterReplace(nil, "Hi all", map[string]stick.Value{"H": "-", "Hi": "Hello", "Hello": "x"}) }, "Hello all"},
+		{"replace positional", func() stick.Value { return filterReplace(nil, "a-b-c", "-", 1) }, "a1b1c"},
+		{"round", func() stick.Value { return filterRound(nil, 2.5) }, 3.0},
+		{"round negative", func() stick.Value { return filterRound(nil, -2.5) }, -3.0},
+		{"round precision", func() stick.Value { return filterRound(nil, 2.1234, 2) }, 2.12},
+		{"round negative precision", func() stick.Value { return filterRound(nil, 1234, -2) }, 1200.0},
+		{"round ceil", func() stick.Value { return filterRound(nil, 2.1234, 1, "ceil") }, 2.2},
+		{"round floor", func() stick.Value { return filterRound(nil, 2.19, 1, "floor") }, 2.1},
+		{"round floor representation error", func() stick.Value { return filterRound(nil, 2.3, 2, "floor") }, 2.3},
+		{"round floor representation error 2", func() stick.Value { return filterRound(nil, 4.35, 2, "floor") }, 4.35},
+		{"round half representation error", func() stick.Value { return filterRound(nil, 1.005, 2) }, 1.01},
+		{"round ceil representation error", func() stick.Value { return filterRound(nil, 0.7, 1, "ceil") }, 0.7},
+		{"round negative precision half", func() stick.Value { return filterRound(nil, 1250, -2) }, 1300.0},
+		{"round unknown method", func() stick.Value { return filterRound(nil, 2.19, 1, "up") }, nil},
+		{"number_format", func() stick.Value { return filterNumberFormat(nil, 1234567.891, 2, ".", ",") }, "1,234,567.89"},
+		{"number_format defaults", func() stick.Value { return filterNumberFormat(nil, 1234567.891) }, "1,234,568"},
+		{"number_format negative", func() stick.Value { return filterNumberFormat(nil, -1234.567, 2, ",", " ") }, "-1 234,57"},
+		{"number_format rounding", func() stick.Value { return filterNumberFormat(nil, 0.125, 2) }, "0.13"},
+		{"number_format representation error", func() stick.Value { return filterNumberFormat(nil, 1.005, 2) }, "1.01"},
+		{"number_format negative representation error", func() stick.Value { return filterNumberFormat(nil, -1.005, 2) }, "-1.01"},
+		{"number_format negative zero", func() stick.Value { return filterNumberFormat(nil, -0.001, 2) }, "0.00"},
+		{"number_format small", func() stick.Value { return filterNumberFormat(nil, 123) }, "123"},
+		{"striptags", func() stick.Value { return filterStripTags(nil, "<p>Hello <b>world</b>!</p><!-- note -->") }, "Hello world!"},
+		{"striptags script", func() stick.Value {
+			return filterStripTags(nil, "Hi<script type=\"text/javascript\">alert('<b>x</b>')</script> there")
+		}, "Hi there"},
+		{"striptags allowed", func() stick.Value {
+			return filterStripTags(nil, "<p>See <a href=\"/x?a=1&amp;b=2\" class=\"l\">this</a><br/>now</p>", "<a><br>")
+		}, "See <a href=\"/x?a=1&amp;b=2\" class=\"l\">this</a><br/>now"},
+		{"striptags allowed list", func() stick.Value { return filterStripTags(nil, "<p><i>a</i> &amp; <b>b</b></p>", []string{"i"}) }, "<i>a</i> &amp; b"},
+		{"striptags malformed", func() stick.Value { return filterStripTags(nil, "a <b class=\"x\" b") }, "a "},
+		{"nl2br", func() stick.Value { return stick.CoerceString(filterNL2BR(nil, "a\nb")) }, "a<br />\nb"},
+		{"nl2br mixed newlines", func() stick.Value { return stick.CoerceString(filterNL2BR(nil, "a\r\nb\nc\rd")) }, "a<br />\r\nb<br />\nc<br />\rd"},
+		{"nl2br escapes", func() stick.Value { return stick.CoerceString(filterNL2BR(nil, "<b>\n")) }, "&lt;b&gt;<br />\n"},
+		{"format", func() stick.Value { return filterFormat(nil, "I like %s and %s.", "foo", "bar") }, "I like foo and bar."},
+		{"format numbers", func() stick.Value { return filterFormat(nil, "%d items at %.2f (%04d) %5.1f%%", "3", 1.5, 42, 3.14159) }, "3 items at 1.50 (0042)   3.1%"},
+		{"format padding", func() stick.Value { return filterFormat(nil, "[%-6s][%6s][%'*6s][%+d][%05d]", "ab", "cd", "ef", 5, -42) }, "[ab    ][    cd][****ef][+5][-0042]"},
+		{"format positional", func() stick.Value { return filterFormat(nil, "%2$s %1$s %2$s", "a", "b") }, "b a b"},
+		{"format hex", func() stick.Value { return filterFormat(nil, "%x %X %b %o %c", 255, 255, 5, 8, 65) }, "ff FF 101 10 A"},
+		{"format missing argument", func() stick.Value { return filterFormat(nil, "%s|%d|%q", "a") }, "a|0|%q"},
+		{"slice", func() stick.Value {
+			return stickSliceToString(filterSlice(nil, []int{1, 2, 3, 4, 5}, 1, 2).([]stick.Value))
+		}, "2.3"},
+		{"slice string", func() stick.Value { return filterSlice(nil, "12345", -2) }, "45"},
+		{"slice negative length", func() stick.Value { return filterSlice(nil, "čeština", 1, -2) }, "ešti"},
+		{"slice out of range", func() stick.Value {
+			return stickSliceToString(filterSlice(nil, []int{1, 2, 3}, -10, 100).([]stick.Value))
+		}, "1.2.3"},
+		{"slice past end", func() stick.Value { return filterSlice(nil, "abc", 5, 2) }, ""},
+		{"slice preserve keys", func() stick.Value {
+			res := filterSlice(nil, []string{"a", "b", "c", "d"}, 2, nil, true).(map[int]stick.Value)
+			return fmt.Sprintf("%d %v %v", len(res), res[2], res[3])
+		}, "2 c d"},
+		{"slice map", func() stick.Value {
+			res := filterSlice(nil, map[string]int{"a": 1, "b": 2, "c": 3}, 1, 1).(map[string]stick.Value)
+			return fmt.Sprintf("%d %v", len(res), res["b"])
+		}, "1 2"},
+		{"date_modify", func() stick.Value {
+			return filterDateModify(nil, "2020-01-01", "+1 day").(time.Time).Format("2006-01-02")
+		}, "2020-01-02"},
+		{"date_modify hours", func() stick.Value {
+			return filterDateModify(nil, "2020-01-01 01:30:00", "-2 hours").(time.Time).Format("2006-01-02 15:04:05")
+		}, "2019-12-31 23:30:00"},
+		{"date_modify multiple units", func() stick.Value {
+			return filterDateModify(nil, time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC), "+1 week -1 month +1 year").(time.Time).Format("2006-01-02")
+		}, "2021-01-07"},
+		{"date_modify invalid modifier", func() stick.Value { return filterDateModify(nil, "2020-01-01", "next tuesday") }, nil},
+		{"date_modify invalid date", func() stick.Value { return filterDateModify(nil, "not a date", "+1 day") }, nil},
+		{"convert_encoding latin1 to utf8", func() stick.Value { return filterConvertEncoding(nil, "caf\xe9 \xa3", "UTF-8", "ISO-8859-1") }, "café £"},
+		{"convert_encoding utf8 to latin1", func() stick.Value { return filterConvertEncoding(nil, "café £", "ISO-8859-1", "UTF-8") }, "caf\xe9 \xa3"},
+		{"convert_encoding windows-1252", func() stick.Value { return filterConvertEncoding(nil, "\x80 \x93", "UTF-8", "Windows-1252") }, "€ “"},
+		{"convert_encoding same charset", func() stick.Value { return filterConvertEncoding(nil, "caf\xe9", "latin1", "ISO-8859-1") }, "caf\xe9"},
+		{"convert_encoding unknown charset", func() stick.Value { return filterConvertEncoding(nil, "abc", "UTF-8", "klingon") }, nil},
+		{"raw", func() stick.Value {
+			res := filterRaw(nil, "<b>").(stick.SafeValue)
+			return fmt.Sprintf("%v %v %v", res.Value(), res.IsSafe("html"), res.IsSafe("js"))
+		}, "<b> true false"},
 		{"join", func() stick.Value { return filterJoin(nil, []string{"a","b","c"}, "-") }, "a-b-c"},
 		{"merge", func() stick.Value { return stickSliceToString(filterMerge(nil, []string{"a","b"}, []string{"c", "d"})) }, "a.b.c.d"},
+		{"take", func() stick.Value { return stickSliceToString(filterTake(nil, []int{1, 2, 3, 4}, 2)) }, "1.2"},
+		{"take more than length", func() stick.Value { return stickSliceToString(filterTake(nil, []int{1, 2, 3}, 10)) }, "1.2.3"},
+		{"take negative", func() stick.Value { return stickSliceToString(filterTake(nil, []int{1, 2, 3, 4}, -2)) }, "3.4"},
+		{"drop", func() stick.Value { return stickSliceToString(filterDrop(nil, []int{1, 2, 3, 4}, 2)) }, "3.4"},
+		{"drop more than length", func() stick.Value { return stickSliceToString(filterDrop(nil, []int{1, 2, 3}, 10)) }, ""},
+		{"drop negative", func() stick.Value { return stickSliceToString(filterDrop(nil, []int{1, 2, 3, 4}, -3)) }, "1"},
+		{"partition even/odd", func() stick.Value { return stickNestedSliceToString(filterPartition(nil, []int{1, 2, 3, 4, 5}, isEven)) }, "2.4|1.3.5|"},
+		{"partition none matching", func() stick.Value { return stickNestedSliceToString(filterPartition(nil, []int{1, 3}, isEven)) }, "|1.3|"},
+		{"transpose", func() stick.Value { return stickNestedSliceToString(filterTranspose(nil, [][]int{{1, 2, 3}, {4, 5, 6}})) }, "1.4|2.5|3.6|"},
+		{"transpose ragged", func() stick.Value { return stickNestedSliceToString(filterTranspose(nil, [][]int{{1, 2}, {3}})) }, "1.3|2.|"},
+		{"to_tree two levels", func() stick.Value { return treeToString(filterToTree(nil, menu)) }, "home(about team) blog(news)"},
+		{"to_tree orphan", func() stick.Value { return treeToString(filterToTree(nil, orphans)) }, "home lost"},
+		{"to_tree cycle", func() stick.Value {
+			return treeToString(filterToTree(nil, []stick.Value{
+				map[string]stick.Value{"id": 1, "parent_id": 2, "name": "a"},
+				map[string]stick.Value{"id": 2, "parent_id": 1, "name": "b"},
+				map[string]stick.Value{"id": 3, "parent_id": 3, "name": "c"},
+			}))
+		}, "b(a) c"},
+		{"to_tree skips non-maps", func() stick.Value {
+			return treeToString(filterToTree(nil, []stick.Value{"x", map[string]stick.Value{"id": 1, "name": "home"}, 5}))
+		}, "home"},
+		{"flatten_tree", func() stick.Value { return flatTreeToString(filterFlattenTree(nil, filterToTree(nil, menu))) }, "0:home 1:about 1:team 0:blog 1:news"},
+		{"flatten_tree custom keys", func() stick.Value {
+			tree := []stick.Value{map[string]stick.Value{"name": "a", "items": []stick.Value{map[string]stick.Value{"name": "b", "items": []stick.Value{map[string]stick.Value{"name": "c"}}}}}}
+			return flatTreeToString(filterFlattenTree(nil, tree, "items", "depth"))
+		}, "0:a 1:b 2:c"},
+		{"wrap scalar", func() stick.Value { return stickSliceToString(filterWrap(nil, "a")) }, "a"},
+		{"wrap list", func() stick.Value { return stickSliceToString(filterWrap(nil, []string{"a", "b"})) }, "a.b"},
+		{"wrap nil", func() stick.Value { l, ok := filterWrap(nil, nil).([]stick.Value); return ok && len(l) == 0 }, true},
+		{"unwrap singleton", func() stick.Value { return filterUnwrap(nil, []string{"a"}) }, "a"},
+		{"unwrap multiple", func() stick.Value { return filterUnwrap(nil, []string{"a", "b"}) }, nil},
+		{"unwrap empty", func() stick.Value { return filterUnwrap(nil, []string{}) }, nil},
+		{"unwrap scalar", func() stick.Value { return filterUnwrap(nil, "a") }, "a"},
+		{"valid email", func() stick.Value { return filterValid(nil, "user@example.com", "email") }, true},
+		{"valid email invalid", func() stick.Value { return filterValid(nil, "user@@example", "email") }, false},
+		{"valid email with name", func() stick.Value { return filterValid(nil, "User <user@example.com>", "email") }, false},
+		{"valid url", func() stick.Value { return filterValid(nil, "https://example.com/path?q=1", "url") }, true},
+		{"valid url invalid", func() stick.Value { return filterValid(nil, "example.com/path", "url") }, false},
+		{"valid ipv4", func() stick.Value { return filterValid(nil, "192.168.0.1", "ipv4") }, true},
+		{"valid ipv4 invalid", func() stick.Value { return filterValid(nil, "256.1.1.1", "ipv4") }, false},
+		{"valid ipv6", func() stick.Value { return filterValid(nil, "2001:db8::1", "ipv6") }, true},
+		{"valid ipv6 invalid", func() stick.Value { return filterValid(nil, "192.168.0.1", "ipv6") }, false},
+		{"valid ipv4 mapped", func() stick.Value { return filterValid(nil, "::ffff:1.2.3.4", "ipv4") }, true},
+		{"valid ipv6 mapped", func() stick.Value { return filterValid(nil, "::ffff:1.2.3.4", "ipv6") }, false},
+		{"valid ipv6 zone", func() stick.Value { return filterValid(nil, "fe80::1%eth0", "ipv6") }, true},
+		{"valid ipv6 empty zone", func() stick.Value { return filterValid(nil, "fe80::1%", "ipv6") }, false},
+		{"valid uuid", func() stick.Value { return filterValid(nil, "123e4567-e89b-12d3-a456-426614174000", "uuid") }, true},
+		{"valid uuid invalid", func() stick.Value { return filterValid(nil, "123e4567-e89b-12d3-a456-42661417400z", "uuid") }, false},
+		{"fraction", func() stick.Value { return filterFraction(nil, 0.75) }, "3/4"},
+		{"fraction negative", func() stick.Value { return filterFraction(nil, -0.125) }, "-1/8"},
+		{"fraction whole", func() stick.Value { return filterFraction(nil, 2.0) }, "2"},
+		{"fraction tiny negative", func() stick.Value { return filterFraction(nil, -1e-7) }, "0"},
+		{"fraction repeating", func() stick.Value { return filterFraction(nil, 1.0/3) }, "1/3"},
+		{"fraction max denominator", func() stick.Value { return filterFraction(nil, 0.2, 4) }, 0.2},
+		{"fraction no approximation", func() stick.Value { return filterFraction(nil, math.Sqrt2) }, math.Sqrt2},
+		{"fraction tolerance", func() stick.Value { return filterFraction(nil, math.Pi, 10, 0.01) }, "22/7"},
+		{"version_compare numeric ordering", func() stick.Value { return filterVersionCompare(nil, "1.2.0", "1.10.0") }, -1},
+		{"version_compare newer", func() stick.Value { return filterVersionCompare(nil, "2.0", "1.10.3") }, 1},
+		{"version_compare equal", func() stick.Value { return filterVersionCompare(nil, "1.2.0", "1.2.0") }, 0},
+		{"version_compare pre-release", func() stick.Value { return filterVersionCompare(nil, "1.0.0-beta", "1.0.0") }, -1},
+		{"version_compare pre-release order", func() stick.Value { return filterVersionCompare(nil, "1.0.0-rc1", "1.0.0-beta2") }, 1},
+		{"version_compare shorter", func() stick.Value { return filterVersionCompare(nil, "1.0", "1.0.1") }, -1},
+		{"version_compare operator", func() stick.Value { return filterVersionCompare(nil, "1.2.0", "1.10.0", "<") }, true},
+		{"version_compare operator false", func() stick.Value { return filterVersionCompare(nil, "1.2.0", "1.10.0", "ge") }, false},
+		{"summarize", func() stick.Value { return filterSummarize(nil, []string{"a", "b", "c", "d", "e"}, 3) }, "a, b, c and 2 more"},
+		{"summarize short", func() stick.Value { return filterSummarize(nil, []string{"a", "b"}, 3) }, "a, b"},
+		{"summarize custom", func() stick.Value { return filterSummarize(nil, []string{"a", "b", "c"}, 1, "/", " (+%d)") }, "a (+2)"},
+		{"summarize suffix without count", func() stick.Value { return filterSummarize(nil, []string{"a", "b", "c"}, 1, ", ", " and more") }, "a and more"},
+		{"summarize suffix with percent", func() stick.Value {
+			return filterSummarize(nil, []string{"a", "b", "c"}, 1, ", ", " +%d (%s, 100%)")
+		}, "a +2 (%s, 100%)"},
+		{"reorder", func() stick.Value {
+			return stickSliceToString(filterReorder(nil, map[string]stick.Value{"name": "n", "email": "e", "age": "a"}, []string{"email", "name", "age"}))
+		}, "e.n.a"},
+		{"reorder missing key", func() stick.Value {
+			return stickSliceToString(filterReorder(nil, map[string]stick.Value{"name": "n", "email": "e"}, []string{"phone", "name"}))
+		}, "n"},
+		{"fragment_encode", func() stick.Value { return filterFragmentEncode(nil, "section two/part?a=1") }, "section%20two/part?a=1"},
+		{"fragment_encode hash and percent", func() stick.Value { return filterFragmentEncode(nil, "50% #off") }, "50%25%20%23off"},
+		{"mailto", func() stick.Value { return stick.CoerceString(filterMailto(nil, "user@example.com")) }, "mailto:user@example.com"},
+		{"mailto with fields", func() stick.Value {
+			return stick.CoerceString(filterMailto(nil, "user@example.com", map[string]stick.Value{"subject": "Hello & welcome", "body": "Line 1\nLine 2?"}))
+		}, "mailto:user@example.com?body=Line%201%0ALine%202%3F&amp;subject=Hello%20%26%20welcome"},
+		{"mailto escapes address", func() stick.Value { return stick.CoerceString(filterMailto(nil, "a&b'c@example.com")) }, "mailto:a&amp;b%27c@example.com"},
+		{"mailto is safe", func() stick.Value { return filterMailto(nil, "user@example.com").(stick.SafeValue).IsSafe("html") }, true},
+		{"css_style", func() stick.Value {
+			return stick.CoerceString(filterCSSStyle(nil, map[string]stick.Value{"font-size": "12px", "color": "red", "margin": 0}))
+		}, "color: red; font-size: 12px; margin: 0;"},
+		{"css_style omits nil", func() stick.Value {
+			return stick.CoerceString(filterCSSStyle(nil, map[string]stick.Value{"color": "red", "background": nil, "border": ""}))
+		}, "color: red;"},
+		{"css_style escapes values", func() stick.Value {
+			return stick.CoerceString(filterCSSStyle(nil, map[string]stick.Value{"font-family": `"Arial"; color: red`}))
+		}, "font-family: &quot;Arial&quot; color: red;"},
+		{"css_style drops script", func() stick.Value {
+			return stick.CoerceString(filterCSSStyle(nil, map[string]stick.Value{
+				"width":      "expression(alert(1))",
+				"background": "URL( 'javascript:alert(1)' )",
+				"behavior":   "url(a.htc)",
+				"color":      `\65xpression(alert(1))`,
+				"height":     "expr/**/ession(alert(1))",
+				"margin":     "0 auto",
+			}))
+		}, "margin: 0 auto;"},
+		{"parse_style", func() stick.Value {
+			return stick.CoerceString(filterCSSStyle(nil, filterParseStyle(nil, "color: red; font-size: 12px")))
+		}, "color: red; font-size: 12px;"},
+		{"parse_style whitespace and trailing semicolon", func() stick.Value {
+			style := filterParseStyle(nil, "  color :red ;  background:  url(a.png)  ; ").(map[string]stick.Value)
+			return stick.CoerceString(len(style)) + "|" + stick.CoerceString(style["color"]) + "|" + stick.CoerceString(style["background"])
+		}, "2|red|url(a.png)"},
+		{"parse_style semicolons in parentheses and quotes", func() stick.Value {
+			style := filterParseStyle(nil, `background: url(data:image/png;base64,AAA); content: "a;b"; color: red`).(map[string]stick.Value)
+			return stick.CoerceString(len(style)) + "|" + stick.CoerceString(style["background"]) + "|" + stick.CoerceString(style["content"])
+		}, `3|url(data:image/png;base64,AAA)|"a;b"`},
+		{"nullsafe nil", func() stick.Value { return filterNullsafe(nil, nil, "—") }, "—"},
+		{"nullsafe nil default", func() stick.Value { return filterNullsafe(nil, nil) }, ""},
+		{"nullsafe empty string", func() stick.Value { return filterNullsafe(nil, "", "—") }, ""},
+		{"nullsafe value", func() stick.Value { return filterNullsafe(nil, 0, "—") }, 0},
+		{"count_values", func() stick.Value {
+			counts := filterCountValues(nil, []stick.Value{"a", "b", "a", 1, "1", "a"}).(map[string]stick.Value)
+			return stickSliceToString(filterReorder(nil, counts, []string{"a", "b", "1"}))
+		}, "3.1.2"},
+		{"count_values empty", func() stick.Value { return filterLength(nil, filterCountValues(nil, []string{})) }, 0},
+		{"duplicates", func() stick.Value {
+			return stickSliceToString(filterDuplicates(nil, []stick.Value{"a", "b", "c", "b", "a", "b", 1, "1"}))
+		}, "b.a.1"},
+		{"duplicates none", func() stick.Value { return stickSliceToString(filterDuplicates(nil, []string{"a", "b", "c"})) }, ""},
+		{"sample", func() stick.Value { return stickSliceToString(filterSample(nil, []int{1, 2, 3, 4, 5}, 2)) }, "1.5"},
+		{"sample more than length", func() stick.Value { return filterLength(nil, filterSample(nil, []int{1, 2, 3}, 10)) }, 3},
+		{"rotate", func() stick.Value { return stickSliceToString(filterRotate(nil, []int{1, 2, 3, 4}, 1)) }, "2.3.4.1"},
+		{"rotate negative", func() stick.Value { return stickSliceToString(filterRotate(nil, []int{1, 2, 3, 4}, -1)) }, "4.1.2.3"},
+		{"rotate more than length", func() stick.Value { return stickSliceToString(filterRotate(nil, []int{1, 2, 3, 4}, 6)) }, "3.4.1.2"},
+		{"key_by", func() stick.Value {
+			users := []stick.Value{
+				map[string]stick.Value{"id": 7, "name": "a"},
+				map[string]stick.Value{"id": 9, "name": "b"},
+				map[string]stick.Value{"name": "no id"},
+			}
+			res := filterKeyBy(nil, users, "id").(map[string]stick.Value)
+			return stick.CoerceString(len(res)) + ":" + namesToString(filterReorder(nil, res, []string{"7", "9"}))
+		}, "2:a b"},
+		{"key_by collision", func() stick.Value {
+			users := []stick.Value{
+				map[string]stick.Value{"role": "admin", "name": "a"},
+				map[string]stick.Value{"role": "user", "name": "b"},
+				map[string]stick.Value{"role": "admin", "name": "c"},
+			}
+			res := filterKeyBy(nil, users, "role").(map[string]stick.Value)
+			return stick.CoerceString(len(res)) + ":" + namesToString(filterReorder(nil, res, []string{"admin", "user"}))
+		}, "2:c b"},
+		{"canonical_json", func() stick.Value {
+			return filterCanonicalJSON(nil, map[string]stick.Value{"b": 1, "a": []stick.Value{map[string]stick.Value{"z": true, "y": nil}, "<x>"}})
+		}, `{"a":[{"y":null,"z":true},"<x>"],"b":1}`},
+		{"canonical_json key order", func() stick.Value {
+			first := map[string]stick.Value{}
+			first["one"], first["two"], first["three"] = 1, 2, 3
+			second := map[interface{}]stick.Value{}
+			second["three"], second["two"], second["one"] = 3, 2, 1
+			return filterCanonicalJSON(nil, first) == filterCanonicalJSON(nil, second)
+		}, true},
+		{"signed positive", func() stick.Value { return filterSigned(nil, 5) }, "+5"},
+		{"signed negative", func() stick.Value { return filterSigned(nil, -2.5) }, "-2.5"},
+		{"signed zero", func() stick.Value { return filterSigned(nil, 0) }, "0"},
+		{"signed zero prefix", func() stick.Value { return filterSigned(nil, "0.00", "±") }, "±0.00"},
+		{"signed formatted", func() stick.Value { return filterSigned(nil, "1,234.50") }, "+1,234.50"},
+		{"clamp below min", func() stick.Value { return filterClamp(nil, -5, 0, 10) }, 0.0},
+		{"clamp in range", func() stick.Value { return filterClamp(nil, "4.5", 0, 10) }, 4.5},
+		{"clamp above max", func() stick.Value { return filterClamp(nil, 15, 0, 10) }, 10.0},
+		{"clamp swapped bounds", func() stick.Value { return filterClamp(nil, 15, 10, 0) }, 10.0},
+		{"rescale midpoint", func() stick.Value { return filterRescale(nil, 5, 0, 10, 0, 100) }, 50.0},
+		{"rescale lower bound", func() stick.Value { return filterRescale(nil, 0, 0, 1023, 0, 255) }, 0.0},
+		{"rescale upper bound", func() stick.Value { return filterRescale(nil, 1023, 0, 1023, 0, 255) }, 255.0},
+		{"rescale inverted", func() stick.Value { return filterRescale(nil, 25, 0, 100, 1, 0) }, 0.75},
+		{"rescale zero width", func() stick.Value { return filterRescale(nil, 5, 3, 3, 0, 100) }, nil},
+		{"stars whole", func() stick.Value { return filterStars(nil, 4, 5) }, "★★★★☆"},
+		{"stars half", func() stick.Value { return filterStars(nil, 3.5, 5) }, "★★★½☆"},
+		{"stars zero", func() stick.Value { return filterStars(nil, 0, 3) }, "☆☆☆"},
+		{"stars custom glyphs", func() stick.Value { return filterStars(nil, 2.3, 4, "*", "-", "") }, "**--"},
+		{"strip_bom utf-8", func() stick.Value { return filterStripBOM(nil, "\xEF\xBB\xBFhello") }, "hello"},
+		{"strip_bom utf-16le", func() stick.Value { return filterStripBOM(nil, "\xFF\xFEh\x00\xE9\x00") }, "hé"},
+		{"strip_bom utf-16be", func() stick.Value { return filterStripBOM(nil, "\xFE\xFF\x00h\x00i") }, "hi"},
+		{"strip_bom none", func() stick.Value { return filterStripBOM(nil, "hello") }, "hello"},
+		{"squeeze spaces", func() stick.Value { return filterSqueeze(nil, "a   b  c\n\nd") }, "a b c\nd"},
+		{"squeeze custom", func() stick.Value { return filterSqueeze(nil, "a--b__c  --d", "-_") }, "a-b_c  -d"},
+		{"reverse_words", func() stick.Value { return filterReverseWords(nil, "the quick brown") }, "brown quick the"},
+		{"reverse_words whitespace", func() stick.Value { return filterReverseWords(nil, "  the  quick\tbrown ") }, "brown quick the"},
+		{"swapcase", func() stick.Value { return filterSwapCase(nil, "Hello, World 42!") }, "hELLO, wORLD 42!"},
+		{"swapcase multibyte", func() stick.Value { return filterSwapCase(nil, "Ärger über Ωmega") }, "äRGER ÜBER ωMEGA"},
+		{"center even", func() stick.Value { return filterCenter(nil, "ab", 6, "*") }, "**ab**"},
+		{"center odd", func() stick.Value { return filterCenter(nil, "héllo", 8) }, " héllo  "},
+		{"center too wide", func() stick.Value { return filterCenter(nil, "hello", 3) }, "hello"},
+		{"enclose default", func() stick.Value { return filterEnclose(nil, "a") }, `"a"`},
+		{"enclose single quote", func() stick.Value { return filterEnclose(nil, "a", "'") }, "'a'"},
+		{"enclose parentheses", func() stick.Value { return filterEnclose(nil, "a", "()") }, "(a)"},
+		{"enclose brackets", func() stick.Value { return filterEnclose(nil, "a", "[]") }, "[a]"},
+		{"enclose braces", func() stick.Value { return filterEnclose(nil, "a", "{") }, "{a}"},
+		{"enclose angle brackets", func() stick.Value { return filterEnclose(nil, "a", "<>") }, "<a>"},
+		{"enclose two-character delimiter", func() stick.Value { return filterEnclose(nil, "a", "/*") }, "/*a/*"},
+		{"enclose pair", func() stick.Value { return filterEnclose(nil, "a", "«", "»") }, "«a»"},
+		{"enclose custom", func() stick.Value { return filterEnclose(nil, "a", "/*", "*/") }, "/*a*/"},
+		{"html_list", func() stick.Value { return stick.CoerceString(filterHTMLList(nil, []string{"a", "<b>"})) }, "<ul><li>a</li><li>&lt;b&gt;</li></ul>"},
+		{"html_list ordered", func() stick.Value { return stick.CoerceString(filterHTMLList(nil, []string{"a"}, "ol")) }, "<ol><li>a</li></ol>"},
+		{"html_list nested", func() stick.Value {
+			return stick.CoerceString(filterHTMLList(nil, []stick.Value{"a", []stick.Value{"b", "c"}, stick.NewSafeValue("<i>d</i>", "html")}))
+		}, "<ul><li>a</li><li><ul><li>b</li><li>c</li></ul></li><li><i>d</i></li></ul>"},
+		{"html_table", func() stick.Value {
+			return stick.CoerceString(filterHTMLTable(nil, []stick.Value{
+				map[string]stick.Value{"name": "a", "qty": 1},
+				map[string]stick.Value{"name": "<b>", "qty": 2},
+			}))
+		}, "<table><thead><tr><th>name</th><th>qty</th></tr></thead><tbody><tr><td>a</td><td>1</td></tr><tr><td>&lt;b&gt;</td><td>2</td></tr></tbody></table>"},
+		{"html_table differing keys", func() stick.Value {
+			return stick.CoerceString(filterHTMLTable(nil, []stick.Value{
+				map[string]stick.Value{"name": "a"},
+				map[string]stick.Value{"qty": 2},
+			}))
+		}, "<table><thead><tr><th>name</th><th>qty</th></tr></thead><tbody><tr><td>a</td><td></td></tr><tr><td></td><td>2</td></tr></tbody></table>"},
+		{"html_table columns", func() stick.Value {
+			return stick.CoerceString(filterHTMLTable(nil, []stick.Value{map[string]stick.Value{"name": "a", "qty": 1, "id": 3}}, []string{"qty", "name"}))
+		}, "<table><thead><tr><th>qty</th><th>name</th></tr></thead><tbody><tr><td>1</td><td>a</td></tr></tbody></table>"},
+		{"dl", func() stick.Value {
+			return stick.CoerceString(filterDL(nil, map[string]stick.Value{"size": "<XL>", "color": "red"}))
+		}, "<dl><dt>color</dt><dd>red</dd><dt>size</dt><dd>&lt;XL&gt;</dd></dl>"},
+		{"dl nil value", func() stick.Value { return stick.CoerceString(filterDL(nil, map[string]stick.Value{"color": nil})) }, "<dl><dt>color</dt><dd></dd></dl>"},
+		{"to_json_array map", func() stick.Value { return filterToJSONArray(nil, map[string]stick.Value{"b": 2, "a": "x", "c": nil}) }, `["x",2,null]`},
+		{"to_json_array list", func() stick.Value { return filterToJSONArray(nil, []int{1, 2, 3}) }, `[1,2,3]`},
+		{"to_json_array scalar", func() stick.Value { return filterToJSONArray(nil, "a") }, `["a"]`},
+		{"to_json_array nil", func() stick.Value { return filterToJSONArray(nil, nil) }, `[]`},
+		{"scientific large", func() stick.Value { return filterScientific(nil, 12345, 2) }, "1.23e+4"},
+		{"scientific small", func() stick.Value { return filterScientific(nil, 0.000123, 1) }, "1.2e-4"},
+		{"scientific default precision", func() stick.Value { return filterScientific(nil, "-6.02e23") }, "-6.020000e+23"},
+		{"scientific zero", func() stick.Value { return filterScientific(nil, 0, 0) }, "0e+0"},
+		{"mod", func() stick.Value { return filterMod(nil, 7, 3) }, 1},
+		{"mod negative dividend", func() stick.Value { return filterMod(nil, -7, 3) }, -1},
+		{"mod negative divisor", func() stick.Value { return filterMod(nil, 7, -3) }, 1},
+		{"mod truncates operands", func() stick.Value { return filterMod(nil, "7.9", 3.2) }, 1},
+		{"mod by zero", func() stick.Value { return filterMod(nil, 7, 0) }, nil},
+		{"power integer", func() stick.Value { return filterPower(nil, 2, 10) }, 1024},
+		{"power fractional exponent", func() stick.Value { return filterPower(nil, 9, 0.5) }, 3.0},
+		{"power negative exponent", func() stick.Value { return filterPower(nil, 2, -1) }, 0.5},
+		{"power zero exponent", func() stick.Value { return filterPower(nil, 5, 0) }, 1},
+		{"gcd list", func() stick.Value { return filterGCD(nil, []int{12, 18, 24}) }, 6},
+		{"gcd coprime", func() stick.Value { return filterGCD(nil, 8, 15) }, 1},
+		{"gcd with zero", func() stick.Value { return filterGCD(nil, []int{0, -10, 4}) }, 2},
+		{"lcm list", func() stick.Value { return filterLCM(nil, []int{4, 6, 10}) }, 60},
+		{"lcm coprime", func() stick.Value { return filterLCM(nil, 8, 15) }, 120},
+		{"lcm with zero", func() stick.Value { return filterLCM(nil, []int{4, 0}) }, 0},
+		{"smart_truncate_html nested", func() stick.Value {
+			return stick.CoerceString(filterSmartTruncateHTML(nil, "<p>Hello <b>brave <i>new</i> world</b> again</p>", 15))
+		}, "<p>Hello <b>brave <i>new</i>…</b></p>"},
+		{"smart_truncate_html hard cut", func() stick.Value {
+			return stick.CoerceString(filterSmartTruncateHTML(nil, "<p>Hello <b>world</b></p>", 8, "...", false))
+		}, "<p>Hello <b>wo...</b></p>"},
+		{"smart_truncate_html entities", func() stick.Value {
+			return stick.CoerceString(filterSmartTruncateHTML(nil, "<p>Fish &amp; chips<br>and peas</p>", 12))
+		}, "<p>Fish &amp; chips<br>…</p>"},
+		{"smart_truncate_html short", func() stick.Value {
+			return stick.CoerceString(filterSmartTruncateHTML(nil, "<p>Hello <b>world</b></p>", 20))
+		}, "<p>Hello <b>world</b></p>"},
+		{"smart_truncate_html zero length", func() stick.Value {
+			return stick.CoerceString(filterSmartTruncateHTML(nil, "<p>Hello</p>", 0))
+		}, "<p>…</p>"},
+		{"smart_truncate_html negative length", func() stick.Value {
+			return stick.CoerceString(filterSmartTruncateHTML(nil, "<p>Hello</p>", -1))
+		}, "<p>…</p>"},
+		{"smart_truncate_html script and style", func() stick.Value {
+			return stick.CoerceString(filterSmartTruncateHTML(nil, "<style>p { color: red; }</style><p>Hello <script>var s = 'a b c';</script>world again</p>", 11))
+		}, "<style>p { color: red; }</style><p>Hello <script>var s = 'a b c';</script>world…</p>"},
+		{"text_direction hebrew", func() stick.Value { return filterTextDirection(nil, "123 שלום world") }, "rtl"},
+		{"text_direction arabic", func() stick.Value { return filterTextDirection(nil, "مرحبا") }, "rtl"},
+		{"text_direction latin", func() stick.Value { return filterTextDirection(nil, "(1) hello שלום") }, "ltr"},
+		{"text_direction neutral", func() stick.Value { return filterTextDirection(nil, "123 !?") }, "ltr"},
+		{"bidi_isolate ltr", func() stick.Value { return stick.CoerceString(filterBidiIsolate(nil, "a<b")) }, "\u2066a&lt;b\u2069"},
+		{"bidi_isolate rtl", func() stick.Value { return stick.CoerceString(filterBidiIsolate(nil, "שלום")) }, "\u2067שלום\u2069"},
+		{"bidi_isolate auto", func() stick.Value { return stick.CoerceString(filterBidiIsolate(nil, "שלום", "auto")) }, "\u2068שלום\u2069"},
+		{"normalize nfc", func() stick.Value { return filterNormalize(nil, "e\u0301cole") }, "\u00e9cole"},
+		{"normalize nfd", func() stick.Value { return filterNormalize(nil, "\u00e9cole", "nfd") }, "e\u0301cole"},
+		{"normalize nfkc", func() stick.Value { return filterNormalize(nil, "\ufb01ne \u2460", "NFKC") }, "fine 1"},
+		{"normalize unknown", func() stick.Value { return filterNormalize(nil, "a", "NFX") }, nil},
+		{"strip_control", func() stick.Value { return filterStripControl(nil, "zero\u200bwidth\ufeff\x00 text\n") }, "zerowidth text\n"},
+		{"strip_control keep", func() stick.Value { return filterStripControl(nil, "a\u200db\u200bc", "\u200d") }, "a\u200dbc"},
+		{"strip_control unchanged", func() stick.Value { return filterStripControl(nil, "Hello,\tworld!") }, "Hello,\tworld!"},
+		{"to_duration string", func() stick.Value { return filterToDuration(nil, "1h30m") }, 90 * time.Minute},
+		{"to_duration seconds", func() stick.Value { return filterToDuration(nil, 90) }, 90 * time.Second},
+		{"to_duration numeric string", func() stick.Value { return filterToDuration(nil, "1.5") }, 1500 * time.Millisecond},
+		{"to_duration garbage", func() stick.Value { return filterToDuration(nil, "soon") }, nil},
+		{"business_days over weekend", func() stick.Value { return filterBusinessDays(nil, "2024-03-08", "2024-03-12") }, 2},
+		{"business_days with holiday", func() stick.Value {
+			return filterBusinessDays(nil, "2024-12-23", "2024-12-30", []string{"2024-12-25", "2024-12-26", "2024-12-28"})
+		}, 3},
+		{"business_days reversed", func() stick.Value { return filterBusinessDays(nil, "2024-03-12 08:00:00", "2024-03-08") }, -2},
+		{"business_days invalid", func() stick.Value { return filterBusinessDays(nil, "soon", "2024-03-08") }, nil},
+		{"iso8601 utc", func() stick.Value { return filterISO8601(nil, "2024-03-08 14:30:00") }, "2024-03-08T14:30:00Z"},
+		{"iso8601 timezone", func() stick.Value { return filterISO8601(nil, "2024-03-08 14:30:00", "Europe/Prague") }, "2024-03-08T15:30:00+01:00"},
+		{"iso8601 time", func() stick.Value { return filterISO8601(nil, testDate, "UTC") }, "1980-05-31T14:01:00Z"},
+		{"iso8601 invalid timezone", func() stick.Value { return filterISO8601(nil, "2024-03-08", "Nowhere/City") }, "2024-03-08T00:00:00Z"},
+		{"timestamp", func() stick.Value { return filterTimestamp(nil, "2024-03-08 14:30:00") }, int64(1709908200)},
+		{"timestamp milliseconds", func() stick.Value { return filterTimestamp(nil, testDate2, "ms") }, int64(1517594504123)},
+		{"timestamp invalid", func() stick.Value { return filterTimestamp(nil, "soon") }, nil},
+		{"date_bucket day", func() stick.Value { return filterDateBucket(nil, "2024-03-08 14:30:00", "day").(time.Time).Format("2006-01-02 15:04") }, "2024-03-08 00:00"},
+		{"date_bucket week", func() stick.Value { return filterDateBucket(nil, "2024-03-08", "week").(time.Time).Format("2006-01-02") }, "2024-03-04"},
+		{"date_bucket week sunday", func() stick.Value { return filterDateBucket(nil, "2024-03-08", "week", "sunday").(time.Time).Format("2006-01-02") }, "2024-03-03"},
+		{"date_bucket week start day", func() stick.Value { return filterDateBucket(nil, "2024-03-04", "week", 1).(time.Time).Format("2006-01-02") }, "2024-03-04"},
+		{"date_bucket month", func() stick.Value { return filterDateBucket(nil, "2024-03-08", "month").(time.Time).Format("2006-01-02") }, "2024-03-01"},
+		{"date_bucket year", func() stick.Value { return filterDateBucket(nil, "2024-03-08", "year").(time.Time).Format("2006-01-02") }, "2024-01-01"},
+		{"date_bucket unknown", func() stick.Value { return filterDateBucket(nil, "2024-03-08", "decade") }, nil},
+		{"list_and one", func() stick.Value { return filterListAnd(nil, []string{"a"}) }, "a"},
+		{"list_and two", func() stick.Value { return filterListAnd(nil, []string{"a", "b"}) }, "a and b"},
+		{"list_and three", func() stick.Value { return filterListAnd(nil, []string{"a", "b", "c"}) }, "a, b, and c"},
+		{"list_and or", func() stick.Value { return filterListAnd(nil, []string{"a", "b", "c", "d"}, "or") }, "a, b, c, or d"},
+		{"json_escape quotes", func() stick.Value { return stick.CoerceString(filterJSONEscape(nil, `say "hi" \o/`)) }, `say \"hi\" \\o/`},
+		{"json_escape newlines", func() stick.Value { return stick.CoerceString(filterJSONEscape(nil, "a\r\nb\tc\x01")) }, `a\r\nb\tc\u0001`},
+		{"json_escape script", func() stick.Value { return stick.CoerceString(filterJSONEscape(nil, "</script><script>")) }, `\u003C/script\u003E\u003Cscript\u003E`},
+		{"class_list", func() stick.Value {
+			return stick.CoerceString(filterClassList(nil, map[string]stick.Value{"btn": true, "active": 1, "disabled": false, "hidden": nil, "btn-lg": "yes"}))
+		}, "active btn btn-lg"},
+		{"class_list all false", func() stick.Value { return stick.CoerceString(filterClassList(nil, map[string]stick.Value{"a": false, "b": 0})) }, ""},
+		{"class_list is safe", func() stick.Value { return filterClassList(nil, map[string]stick.Value{"a": true}).(stick.SafeValue).IsSafe("html_attr") }, true},
+		{"checkmark truthy", func() stick.Value { return stick.CoerceString(filterCheckmark(nil, 1)) }, "✓"},
+		{"checkmark falsy", func() stick.Value { return stick.CoerceString(filterCheckmark(nil, "")) }, "✗"},
+		{"checkmark custom", func() stick.Value { return stick.CoerceString(filterCheckmark(nil, true, "yes", "no")) }, "yes"},
+		{"checkmark nil custom", func() stick.Value { return stick.CoerceString(filterCheckmark(nil, nil, "yes", "no")) }, "no"},
+		{"checkmark escapes glyphs", func() stick.Value { return stick.CoerceString(filterCheckmark(nil, true, "<b>yes</b>")) }, "&lt;b&gt;yes&lt;/b&gt;"},
+		{"checkmark safe glyphs", func() stick.Value {
+			return stick.CoerceString(filterCheckmark(nil, false, "", stick.NewSafeValue(`<i class="no"></i>`, "html")))
+		}, `<i class="no"></i>`},
+		{"reading_time short", func() stick.Value { return filterReadingTime(nil, "Just a few words.") }, 1},
+		{"reading_time long", func() stick.Value { return filterReadingTime(nil, strings.Repeat("word ", 450)) }, 3},
+		{"reading_time ignores tags", func() stick.Value {
+			return filterReadingTime(nil, "<p class=\"intro lead\">one <b>two</b></p><img src=\"a.png\" alt=\"x\"> three four", 2)
+		}, 2},
+		{"reading_time empty", func() stick.Value { return filterReadingTime(nil, "") }, 0},
+		{"hashtags", func() stick.Value {
+			return stickSliceToString(filterHashtags(nil, "Loving #golang, #Go_1 and #日本! Not this#one or # alone.").([]stick.Value))
+		}, "golang.Go_1.日本"},
+		{"hashtags custom prefix", func() stick.Value {
+			return stickSliceToString(filterHashtags(nil, "Tickets $ABC and ($XYZ).", "$").([]stick.Value))
+		}, "ABC.XYZ"},
+		{"mentions", func() stick.Value {
+			return stickSliceToString(filterMentions(nil, "Thanks @alice, (@bob) — mail me at carol@example.com.").([]stick.Value))
+		}, "alice.bob"},
+		{"mentions none", func() stick.Value { return len(filterMentions(nil, "nothing here").([]stick.Value)) }, 0},
+		{"anonymize_ip ipv4", func() stick.Value { return filterAnonymizeIP(nil, "192.168.10.123") }, "192.168.10.0"},
+		{"anonymize_ip ipv6", func() stick.Value { return filterAnonymizeIP(nil, "2001:db8:85a3:8d3:1319:8a2e:370:7348") }, "2001:db8:85a3::"},
+		{"anonymize_ip not an ip", func() stick.Value { return filterAnonymizeIP(nil, "localhost") }, "localhost"},
+		{"coordinate latitude", func() stick.Value { return filterCoordinate(nil, 40.7128, "lat") }, "40°42'46\"N"},
+		{"coordinate longitude", func() stick.Value { return filterCoordinate(nil, -74.006, "lon") }, "74°0'22\"W"},
+		{"coordinate carries seconds", func() stick.Value { return filterCoordinate(nil, -33.9999999) }, "34°0'0\"S"},
+		{"coordinate unknown axis", func() stick.Value { return filterCoordinate(nil, 1, "alt") }, nil},
+		{"pct_change increase", func() stick.Value { return filterPctChange(nil, 125, 100) }, "25.0%"},
+		{"pct_change decrease", func() stick.Value { return filterPctChange(nil, 2, 3, 2, true) }, "-33.33%"},
+		{"pct_change signed", func() stick.Value { return filterPctChange(nil, 150, 100, 0, true) }, "+50%"},
+		{"pct_change negative base", func() stick.Value { return filterPctChange(nil, -50, -100) }, "50.0%"},
+		{"pct_change no change", func() stick.Value { return filterPctChange(nil, 100, 100, 1, true) }, "0.0%"},
+		{"pct_change zero base", func() stick.Value { return filterPctChange(nil, 10, 0) }, nil},
+		{"rank", func() stick.Value {
+			return stickSliceToString([]stick.Value{filterRank(nil, 1), filterRank(nil, 2), filterRank(nil, "3"), filterRank(nil, 4)})
+		}, "🥇.🥈.🥉.4th"},
+		{"rank large", func() stick.Value {
+			return stickSliceToString([]stick.Value{filterRank(nil, 11), filterRank(nil, 22), filterRank(nil, 113), filterRank(nil, 1001)})
+		}, "11th.22nd.113th.1001st"},
+		{"rank custom medals", func() stick.Value {
+			return stickSliceToString([]stick.Value{filterRank(nil, 1, []string{"gold", "silver"}), filterRank(nil, 3, []string{"gold", "silver"})})
+		}, "gold.3rd"},
+		{"break_long_words url", func() stick.Value {
+			return filterBreakLongWords(nil, "see https://example.com/a/very/long/path", 10)
+		}, "see https://ex\u200Bample.com/\u200Ba/very/lon\u200Bg/path"},
+		{"break_long_words wbr", func() stick.Value {
+			return stick.CoerceString(filterBreakLongWords(nil, "a&b<c>defgh ok", 4, "wbr"))
+		}, "a&amp;b&lt;<wbr>c&gt;de<wbr>fgh ok"},
+		{"break_long_words normal text", func() stick.Value {
+			return filterBreakLongWords(nil, "The quick brown fox jumps over the lazy dog.", 10)
+		}, "The quick brown fox jumps over the lazy dog."},
+		{"break_long_words unknown strategy", func() stick.Value { return filterBreakLongWords(nil, "abc", 1, "shy") }, nil},
+		{"natsort", func() stick.Value {
+			return stickSliceToString(filterNatSort(nil, []string{"file10.txt", "file2.txt", "file1.txt", "File3.txt", "file02.txt"}))
+		}, "File3.txt.file1.txt.file02.txt.file2.txt.file10.txt"},
+		{"natsort lexical sort differs", func() stick.Value {
+			return stickSliceToString(filterSort(nil, []string{"file10", "file2", "file1"}))
+		}, "file1.file10.file2"},
+		{"natsort versions desc", func() stick.Value {
+			return stickSliceToString(filterNatSort(nil, []string{"v1.9", "v1.10", "v1.9.1", "v2"}, "desc"))
+		}, "v2.v1.10.v1.9.1.v1.9"},
+		{"natsort map", func() stick.Value {
+			return stickSliceToString(filterNatSort(nil, map[string]string{"a": "img12", "b": "img10", "c": "img2"}))
+		}, "img2.img10.img12"},
+		{"string_to_color stable", func() stick.Value {
+			return filterStringToColor(nil, "alice") == filterStringToColor(nil, "alice")
+		}, true},
+		{"string_to_color differs", func() stick.Value {
+			return filterStringToColor(nil, "alice") != filterStringToColor(nil, "bob")
+		}, true},
+		{"string_to_color hex", func() stick.Value {
+			res := []stick.Value{}
+			for _, palette := range []string{"normal", "pastel", "dark"} {
+				for _, in := range []string{"", "alice", "bob", "日本"} {
+					c := stick.CoerceString(filterStringToColor(nil, in, palette))
+					_, err := strconv.ParseUint(strings.TrimPrefix(c, "#"), 16, 32)
+					res = append(res, len(c) == 7 && c[0] == '#' && err == nil && c == strings.ToLower(c))
+				}
+			}
+			return stickSliceToString(res)
+		}, "1.1.1.1.1.1.1.1.1.1.1.1"},
+		{"string_to_color pastel", func() stick.Value { return filterStringToColor(nil, "alice", "pastel") }, "#a8a9f0"},
+		{"string_to_color unknown palette", func() stick.Value { return filterStringToColor(nil, "alice", "neon") }, nil},
+		{"morse", func() stick.Value { return filterMorse(nil, "SOS") }, "... --- ..."},
+		{"morse words", func() stick.Value { return filterMorse(nil, "Hi there, 42!") }, ".... .. / - .... . .-. . / ....- ..---"},
+		{"morse custom symbols", func() stick.Value { return filterMorse(nil, "sos", "·", "–", "|") }, "···|–––|···"},
+		{"morse unsupported only", func() stick.Value { return filterMorse(nil, "?! ok") }, "--- -.-"},
+		{"radix hex", func() stick.Value { return filterRadix(nil, 255, 16, 0, "0x") }, "0xff"},
+		{"radix default", func() stick.Value { return filterRadix(nil, "48879") }, "beef"},
+		{"radix binary nibbles", func() stick.Value { return filterRadix(nil, 165, 2, 4, "0b") }, "0b1010 0101"},
+		{"radix binary padded", func() stick.Value { return filterRadix(nil, 37, 2, 4, "", "_") }, "0010_0101"},
+		{"radix negative", func() stick.Value { return filterRadix(nil, -10, 36, 0, "#") }, "-#a"},
+		{"radix invalid base", func() stick.Value { return filterRadix(nil, 10, 37) }, nil},
+		{"sentence_case", func() stick.Value {
+			return filterSentenceCase(nil, "THIS IS LOUD. WHY ARE WE SHOUTING?  NO IDEA! \"REALLY.\" YES")
+		}, "This is loud. Why are we shouting?  No idea! \"Really.\" Yes"},
+		{"sentence_case single sentence", func() stick.Value { return filterSentenceCase(nil, "hello WORLD") }, "Hello world"},
+		{"sentence_case abbreviations", func() stick.Value {
+			return filterSentenceCase(nil, "MEET DR. SMITH AT 3.30 P.M. TODAY, E.G. AT NOON. PLAN B. OK")
+		}, "Meet dr. smith at 3.30 p.m. today, e.g. at noon. Plan b. Ok"},
+		{"sentence_case common words", func() stick.Value { return filterSentenceCase(nil, "THE ANSWER IS NO. WE LEAVE") }, "The answer is no. We leave"},
+		{"sentence_case single letter", func() stick.Value { return filterSentenceCase(nil, "I GOT AN A. THEN I LEFT.") }, "I got an a. Then i left."},
+		{"sentence_case title case", func() stick.Value { return filterSentenceCase(nil, "ǆungla. ǄEP") }, "ǅungla. ǅep"},
+		{"sentence_case leading punctuation", func() stick.Value { return filterSentenceCase(nil, "...¿QUÉ? «ÉL» VINO.") }, "...¿Qué? «Él» vino."},
+		{"initials", func() stick.Value { return filterInitials(nil, "Ada Lovelace") }, "AL"},
+		{"initials three parts", func() stick.Value { return filterInitials(nil, "John Ronald Tolkien") }, "JRT"},
+		{"initials max", func() stick.Value { return filterInitials(nil, "John Ronald Tolkien", 2) }, "JR"},
+		{"initials hyphenated", func() stick.Value { return filterInitials(nil, "Mary Smith-Jones") }, "MSJ"},
+		{"initials accented", func() stick.Value { return filterInitials(nil, " émile  (Östen) ") }, "ÉÖ"},
+		{"rot13", func() stick.Value { return filterRot13(nil, "Hello, World!") }, "Uryyb, Jbeyq!"},
+		{"rot13 round trip", func() stick.Value { return filterRot13(nil, filterRot13(nil, "Straße 42, Zürich")) }, "Straße 42, Zürich"},
+		{"rot13 custom shift", func() stick.Value { return filterRot13(nil, "xyz ABC", 3) }, "abc DEF"},
+		{"rot13 negative shift", func() stick.Value { return filterRot13(nil, "abc DEF", -3) }, "xyz ABC"},
+		{"rot13 large shift", func() stick.Value { return filterRot13(nil, "abc", 53) }, "bcd"},
+		{"bool_string", func() stick.Value {
+			return stickSliceToString([]stick.Value{filterBoolString(nil, true), filterBoolString(nil, false), filterBoolString(nil, nil)})
+		}, "true.false.false"},
+		{"bool_string yes", func() stick.Value {
+			return stickSliceToString([]stick.Value{filterBoolString(nil, "x", "yes"), filterBoolString(nil, 0, "yes"), filterBoolString(nil, nil, "YES")})
+		}, "yes.no.no"},
+		{"bool_string numeric", func() stick.Value {
+			return stickSliceToString([]stick.Value{filterBoolString(nil, 3, "1"), filterBoolString(nil, "", "1"), filterBoolString(nil, nil, 1)})
+		}, "1.0.0"},
+		{"bool_string on", func() stick.Value {
+			return stickSliceToString([]stick.Value{filterBoolString(nil, true, "on"), filterBoolString(nil, false, "on"), filterBoolString(nil, nil, "on")})
+		}, "on.off.off"},
+		{"bool_string unknown style", func() stick.Value { return filterBoolString(nil, true, "maybe") }, nil},
+		{"get first", func() stick.Value { return filterGet(nil, []stick.Value{"a", "b", "c"}, float64(0)) }, "a"},
+		{"get matches first", func() stick.Value {
+			list := []stick.Value{"a", "b", "c"}
+			return filterGet(nil, list, float64(0)) == filterFirst(nil, list)
+		}, true},
+		{"get index", func() stick.Value { return filterGet(nil, []stick.Value{"a", "b", "c"}, 2) }, "c"},
+		{"get negative index", func() stick.Value { return filterGet(nil, []stick.Value{"a", "b", "c"}, float64(-1)) }, "c"},
+		{"get negative index from end", func() stick.Value { return filterGet(nil, []stick.Value{"a", "b", "c"}, -3) }, "a"},
+		{"get int64 index", func() stick.Value { return filterGet(nil, []stick.Value{"a", "b", "c"}, int64(1)) }, "b"},
+		{"get string key on list", func() stick.Value { return filterGet(nil, []stick.Value{"a", "b"}, "1") }, nil},
+		{"get missing string key on list", func() stick.Value { return filterGet(nil, []stick.Value{"a", "b"}, "x") }, nil},
+		{"get out of range", func() stick.Value { return filterGet(nil, []stick.Value{"a", "b", "c"}, float64(3)) }, nil},
+		{"get negative out of range", func() stick.Value { return filterGet(nil, []stick.Value{"a", "b", "c"}, float64(-4)) }, nil},
+		{"get map", func() stick.Value { return filterGet(nil, map[string]stick.Value{"a": 1}, "a") }, 1},
+		{"get missing map key", func() stick.Value { return filterGet(nil, map[string]stick.Value{"a": 1}, "b") }, nil},
 	}
 	for _, test := range tests {
 		res := test.actual()
@@ -73,6 +748,63 @@ func TestFilters(t *testing.T) {
 	}
 }
 
+func TestStandardDatePatternToGoDatePattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"yyyy-MM-dd hh:mm", "2006-01-02 03:04"},
+		{"yyyy-MM-dd HH:mm:ss", "2006-01-02 15:04:05"},
+		{"d/M/yy H:m:s", "2/1/06 15:4:5"},
+		{"yyyyMMdd", "20060102"},
+		{"hhHHh", "03153"},
+		{"MMM", "Jan"},
+		{"MMMMM", "January1"},
+		{"EEEE, MMMM d", "Monday, January 2"},
+		{"EEE dd MMM", "Mon 02 Jan"},
+		{"hh:mm a", "03:04 pm"},
+		{"h:mm A", "3:04 PM"},
+		{"yyyy-MM-dd'T'HH:mm:ss.SSSZ", "2006-01-02T15:04:05.000-0700"},
+		{"HH:mm:ssZZ", "15:04:05Z07:00"},
+		{"yyyyy", "2006y"},
+		{"@ ss", "@ 05"},
+		{"HH'h'mm", "15h04"},
+		{"yyyy 'year'", "2006 year"},
+		{"hh 'o''clock'", "03 o'clock"},
+		{"''yy", "'06"},
+		{"'unterminated yyyy", "unterminated yyyy"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if res := StandardDatePatternToGoDatePattern(test.pattern); res != test.expected {
+			t.Errorf("%q:\n\texpected: %v\n\tgot: %v", test.pattern, test.expected, res)
+		}
+	}
+}
+
+func TestFormatStandardDate(t *testing.T) {
+	awst := time.FixedZone("AWST", 8*60*60)
+	d := time.Date(2018, 2, 3, 14, 1, 44, 123456789, awst)
+	tests := []struct {
+		pattern  string
+		date     time.Time
+		expected string
+	}{
+		{"yyyy-MM-dd'T'HH:mm:ss.SSSZ", d, "2018-02-03T14:01:44.123+0800"},
+		{"yyyy-MM-dd'T'HH:mm:ssZZ", d, "2018-02-03T14:01:44+08:00"},
+		{"HH:mm:ss.SSSZZ", d.UTC(), "06:01:44.123Z"},
+		{"ss SSS Z", d.In(time.FixedZone("", -(3*60+30)*60)), "44 123 -0330"},
+	}
+	for _, test := range tests {
+		if res := FormatStandardDate(test.date, test.pattern); res != test.expected {
+			t.Errorf("%q:\n\texpected: %v\n\tgot: %v", test.pattern, test.expected, res)
+		}
+		if res := test.date.Format(StandardDatePatternToGoDatePattern(test.pattern)); strings.Contains(test.pattern, ".SSS") && res != test.expected {
+			t.Errorf("%q as Go layout:\n\texpected: %v\n\tgot: %v", test.pattern, test.expected, res)
+		}
+	}
+}
+
 func stickSliceToString(value stick.Value) (output string) {
 	var slice []string
 	stick.Iterate(value, func(k, v stick.Value, l stick.Loop) (bool, error) {
@@ -82,3 +814,48 @@ func stickSliceToString(value stick.Value) (output string) {
 
 	return strings.Join(slice, ".")
 }
+
+func stickNestedSliceToString(value stick.Value) (output string) {
+	stick.Iterate(value, func(k, v stick.Value, l stick.Loop) (bool, error) {
+		output += stickSliceToString(v) + "|"
+		return false, nil
+	})
+
+	return output
+}
+
+func treeToString(value stick.Value) string {
+	var nodes []string
+	stick.Iterate(value, func(k, v stick.Value, l stick.Loop) (bool, error) {
+		node := v.(map[string]stick.Value)
+		s := stick.CoerceString(node["name"])
+		if children := treeToString(node["children"]); children != "" {
+			s += "(" + children + ")"
+		}
+		nodes = append(nodes, s)
+		return false, nil
+	})
+
+	return strings.Join(nodes, " ")
+}
+
+func flatTreeToString(value stick.Value) string {
+	var nodes []string
+	stick.Iterate(value, func(k, v stick.Value, l stick.Loop) (bool, error) {
+		node := v.(map[string]stick.Value)
+		nodes = append(nodes, stick.CoerceString(node["depth"])+":"+stick.CoerceString(node["name"]))
+		return false, nil
+	})
+
+	return strings.Join(nodes, " ")
+}
+
+func namesToString(value stick.Value) string {
+	var names []string
+	stick.Iterate(value, func(k, v stick.Value, l stick.Loop) (bool, error) {
+		names = append(names, stick.CoerceString(v.(map[string]stick.Value)["name"]))
+		return false, nil
+	})
+
+	return strings.Join(names, " ")
+}
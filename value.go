@@ -63,6 +63,26 @@ func (v safeValue) SafeFor() []string {
 	return r
 }
 
+// A SafeString is a string that has already been sanitized for HTML. It is
+// not escaped when output in an HTML context, but an explicit escape for any
+// other content type, such as "js", still escapes it.
+type SafeString string
+
+// Value returns the string stored in the SafeString.
+func (s SafeString) Value() Value {
+	return string(s)
+}
+
+// IsSafe returns true if typ is "html".
+func (s SafeString) IsSafe(typ string) bool {
+	return typ == "html"
+}
+
+// SafeFor returns the content types a SafeString is safe for, only "html".
+func (s SafeString) SafeFor() []string {
+	return []string{"html"}
+}
+
 // Stringer is implemented by any value that has a String method.
 type Stringer interface {
 	fmt.Stringer